@@ -2,87 +2,110 @@ package amp
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mokelab-go/markdown"
 	"github.com/mokelab-go/markdown/ast"
+	"github.com/mokelab-go/markdown/render"
 )
 
-type impl struct {
+// Option configures the compiler
+type Option = render.Option
+
+// Options are the ones of render package except WithXHTML
+var (
+	WithHighlight      = render.WithHighlight
+	WithLenient        = render.WithLenient
+	WithTransformers   = render.WithTransformers
+	WithHeadingIDs     = render.WithHeadingIDs
+	WithPermalinks     = render.WithPermalinks
+	WithUnsafe         = render.WithUnsafe
+	WithAllowedSchemes = render.WithAllowedSchemes
+	WithHTMLPolicy     = render.WithHTMLPolicy
+	WithHook           = render.WithHook
+	WithParser         = render.WithParser
+)
+
+// NewMarkdown returns the compiler which prints markdown as AMP html
+func NewMarkdown(opts ...Option) markdown.Renderer {
+	return render.New(Funcs(), opts...)
+}
+
+// Funcs returns the functions which print blocks as AMP html
+func Funcs() render.Funcs {
+	funcs := render.HTMLFuncs()
+	funcs[ast.TypeLI] = printLI
+	funcs[ast.TypeImage] = printImage
+	funcs[ast.TypeTableCell] = printTableCell
+	funcs[ast.TypeHTMLBlock] = printHTMLBlock
+	funcs[ast.TypeHTML] = printHTML
+	return funcs
 }
 
-func NewMarkdown() markdown.Markdown {
-	return &impl{}
+func printLI(c *render.Context, out []byte, block *ast.Block) []byte {
+	checked, task := block.Attributes["checked"]
+	if !task {
+		out = render.AppendStr(out, " <li>")
+	} else if checked == "true" {
+		out = render.AppendStr(out, " <li class=\"task-list-item\">")
+		out = render.AppendStr(out, "<span class=\"task-list-item-checkbox\" role=\"checkbox\" aria-checked=\"true\" aria-disabled=\"true\">&#x2611;</span> ")
+	} else {
+		out = render.AppendStr(out, " <li class=\"task-list-item\">")
+		out = render.AppendStr(out, "<span class=\"task-list-item-checkbox\" role=\"checkbox\" aria-checked=\"false\" aria-disabled=\"true\">&#x2610;</span> ")
+	}
+	out = c.Children(out, block)
+	return render.AppendStr(out, " </li>\n")
+}
+
+func printImage(c *render.Context, out []byte, block *ast.Block) []byte {
+	width := block.Attributes["width"]
+	height := block.Attributes["height"]
+	return render.AppendStr(out, fmt.Sprintf("<amp-img src=\"%s\" title=\"%s\" width=\"%s\" height=\"%s\"></amp-img>",
+		render.Escape(c.URL(block.URL)),
+		render.Escape(block.Value),
+		render.Escape(width),
+		render.Escape(height)))
 }
 
-func (o *impl) Compile(src string) (string, error) {
-	tree, err := ast.Parse(src)
-	if err != nil {
-		return "", err
+func printTableCell(c *render.Context, out []byte, block *ast.Block) []byte {
+	tag := render.CellTag(c)
+	if align := block.Attributes["align"]; len(align) > 0 {
+		out = render.AppendStr(out, fmt.Sprintf("<%s align=\"%s\">", tag, align))
+	} else {
+		out = render.AppendStr(out, "<"+tag+">")
 	}
-	out := make([]byte, 0, len(src)*2)
-	out = printBlock(out, tree)
-	return string(out), nil
+	out = c.Children(out, block)
+	return render.AppendStr(out, "</"+tag+">\n")
 }
 
-func appendStr(out []byte, text string) []byte {
-	return append(out, text...)
+// tags which AMP does not allow in the body. Some of them have
+// AMP components such as amp-img instead.
+var forbiddenTags = map[string]bool{
+	"applet": true, "audio": true, "base": true, "embed": true,
+	"frame": true, "frameset": true, "iframe": true, "img": true,
+	"link": true, "meta": true, "object": true, "param": true,
+	"picture": true, "script": true, "style": true, "video": true,
 }
 
-func printBlock(out []byte, block *ast.Block) []byte {
-	switch block.Type {
-	case ast.TypeRoot:
-		return printChildren(out, block)
-	case ast.TypeH1:
-		out = appendStr(out, "<h1>")
-		out = printChildren(out, block)
-		out = appendStr(out, "</h1>\n\n")
-	case ast.TypeH2:
-		out = appendStr(out, "<h2>")
-		out = printChildren(out, block)
-		out = appendStr(out, "</h2>\n\n")
-	case ast.TypeP:
-		out = appendStr(out, "<p>")
-		out = printChildren(out, block)
-		out = appendStr(out, "</p>\n\n")
-	case ast.TypePreCode:
-		out = appendStr(out, "<pre><code>")
-		out = printChildren(out, block)
-		out = appendStr(out, "</code></pre>\n\n")
-	case ast.TypeUL:
-		out = appendStr(out, "<ul>\n")
-		out = printChildren(out, block)
-		out = appendStr(out, "</ul>\n\n")
-	case ast.TypeLI:
-		out = appendStr(out, " <li>")
-		out = printChildren(out, block)
-		out = appendStr(out, " </li>\n")
-	case ast.TypeAnchor:
-		out = appendStr(out, fmt.Sprintf("<a href=\"%s\">%s</a>", block.URL, block.Value))
-	case ast.TypeImage:
-		width := block.Attributes["width"]
-		height := block.Attributes["height"]
-		out = appendStr(out, fmt.Sprintf("<amp-img src=\"%s\" title=\"%s\" width=\"%s\" height=\"%s\"></amp-img>",
-			block.URL,
-			block.Value,
-			width,
-			height))
-	case ast.TypeText:
-		if len(block.Value) == 0 {
-			out = printChildren(out, block)
-		} else {
-			out = appendStr(out, block.Value)
-		}
-	case ast.TypeCode:
-		out = appendStr(out, "<code>")
-		out = appendStr(out, block.Value)
-		out = appendStr(out, "</code>\n\n")
+// forbidden reports whether AMP does not allow the tag or the attribute.
+// Event handler attributes are not allowed except "on" of AMP actions.
+func forbidden(tag, attr string) bool {
+	if attr == "" {
+		return forbiddenTags[tag]
 	}
-	return out
+	return strings.HasPrefix(attr, "on") && attr != "on"
 }
 
-func printChildren(out []byte, block *ast.Block) []byte {
-	for _, e := range block.Children {
-		out = printBlock(out, e)
+func printHTMLBlock(c *render.Context, out []byte, block *ast.Block) []byte {
+	n := len(out)
+	out = c.HTML(out, block.Value, forbidden)
+	if len(out) == n {
+		// everything is removed
+		return out
 	}
-	return out
+	return render.AppendStr(out, "\n")
+}
+
+func printHTML(c *render.Context, out []byte, block *ast.Block) []byte {
+	return c.HTML(out, block.Value, forbidden)
 }
@@ -2,7 +2,11 @@ package amp
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/mokelab-go/markdown/ast"
+	"github.com/mokelab-go/markdown/render"
 )
 
 const markdown_1 = "# OK\n\n" +
@@ -119,3 +123,381 @@ func Test_3(t *testing.T) {
 	}
 	fmt.Printf(out)
 }
+
+func Test_Headings(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("### h3\n\n#### h4\n\n##### h5\n\n###### h6\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<h3>h3</h3>\n\n<h4>h4</h4>\n\n<h5>h5</h5>\n\n<h6>h6</h6>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_OrderedList(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("1. one\n2. two\n\n7) seven\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<ol>\n <li>one </li>\n <li>two </li>\n</ol>\n\n" +
+		"<ol start=\"7\">\n <li>seven </li>\n</ol>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_NestedList(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile(" * a\n   * b\n * c\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<ul>\n <li>a<ul>\n <li>b </li>\n</ul>\n\n </li>\n <li>c </li>\n</ul>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Emphasis(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("*em* **strong** ~~del~~")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p><em>em</em> <strong>strong</strong> <del>del</del></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Blockquote(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("> quote\n> > nested")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<blockquote>\n<p>quote</p>\n\n" +
+		"<blockquote>\n<p>nested</p>\n\n</blockquote>\n\n" +
+		"</blockquote>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_CodeLanguage(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("```kotlin\nval a = 1\n```\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<pre><code class=\"language-kotlin\">val a = 1\n</code></pre>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Highlight(t *testing.T) {
+	src := "```go\nreturn \"<a>\"\n```\n"
+	out, err := NewMarkdown(WithHighlight()).Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<pre><code class=\"language-go\">" +
+		"<span class=\"hl-keyword\">return</span> <span class=\"hl-string\">&quot;&lt;a&gt;&quot;</span>\n" +
+		"</code></pre>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+	// disabled by default
+	out, err = NewMarkdown().Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected = "<pre><code class=\"language-go\">return &quot;&lt;a&gt;&quot;\n</code></pre>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Table(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("| a | b |\n|---|:-:|\n| 1 | 2 |\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<table>\n<thead>\n<tr>\n<th>a</th>\n<th align=\"center\">b</th>\n</tr>\n</thead>\n" +
+		"<tbody>\n<tr>\n<td>1</td>\n<td align=\"center\">2</td>\n</tr>\n</tbody>\n</table>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_TaskList(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("- [ ] todo\n- [x] done\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<ul>\n" +
+		" <li class=\"task-list-item\"><span class=\"task-list-item-checkbox\" role=\"checkbox\" aria-checked=\"false\" aria-disabled=\"true\">&#x2610;</span> todo </li>\n" +
+		" <li class=\"task-list-item\"><span class=\"task-list-item-checkbox\" role=\"checkbox\" aria-checked=\"true\" aria-disabled=\"true\">&#x2611;</span> done </li>\n" +
+		"</ul>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Lenient(t *testing.T) {
+	src := "####### seven\n\nok\n"
+	if _, err := NewMarkdown().Compile(src); err == nil {
+		t.Errorf("strict mode must fail")
+	}
+	warnings := make([]*ast.ParseError, 0)
+	m := NewMarkdown(WithLenient(func(w *ast.ParseError) {
+		warnings = append(warnings, w)
+	}))
+	out, err := m.Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p>####### seven</p>\n\n<p>ok</p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != ast.ErrHeadingLevel || warnings[0].Line != 1 {
+		t.Errorf("warnings must have the heading level error but %v", warnings)
+	}
+}
+
+func Test_Escape(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile("a < b & \"c\" [<x>](./a?b=1&c=\"2\") `<br>`\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p>a &lt; b &amp; &quot;c&quot; " +
+		"<a href=\"./a?b=1&amp;c=&quot;2&quot;\">&lt;x&gt;</a> <code>&lt;br&gt;</code>\n\n</p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Transformers(t *testing.T) {
+	cdn := ast.TransformerFunc(func(root *ast.Block) error {
+		ast.Walk(root, func(node *ast.Block, entering bool) ast.WalkStatus {
+			if entering && node.Type == ast.TypeImage && strings.HasPrefix(node.URL, "./") {
+				node.URL = "https://cdn.example.com/" + node.URL[2:]
+			}
+			return ast.WalkContinue
+		})
+		return nil
+	})
+	dropDraft := ast.TransformerFunc(func(root *ast.Block) error {
+		children := make([]*ast.Block, 0, len(root.Children))
+		for _, c := range root.Children {
+			if ast.IsHeading(c.Type) && c.Children[0].Value == "Draft" {
+				break
+			}
+			children = append(children, c)
+		}
+		root.Children = children
+		return nil
+	})
+	m := NewMarkdown(WithTransformers(cdn), WithTransformers(dropDraft))
+	out, err := m.Compile("![logo](./logo.png)\n\n## Draft\n\nwip\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p><amp-img src=\"https://cdn.example.com/logo.png\" title=\"logo\" width=\"\" height=\"\"></amp-img></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+
+	failed := ast.TransformerFunc(func(root *ast.Block) error {
+		return fmt.Errorf("failed")
+	})
+	if _, err := NewMarkdown(WithTransformers(failed)).Compile("a"); err == nil {
+		t.Errorf("Compile must return the error of the transformer")
+	}
+}
+
+func Test_Render(t *testing.T) {
+	m := NewMarkdown()
+	srcs := []string{"# Title\n\n* a\n* b\n\ntext\n", "a\n  \nb\n", "```\n\nx\n\n``` y\n\n[z\n\n# w\n"}
+	var w strings.Builder
+	for _, src := range srcs {
+		expected, err := m.Compile(src)
+		if err != nil {
+			t.Errorf("error : %s", err)
+			return
+		}
+		w.Reset()
+		if err := m.Render(&w, []byte(src)); err != nil {
+			t.Errorf("error : %s", err)
+			return
+		}
+		if w.String() != expected {
+			t.Errorf("Output must be %s but %s", expected, w.String())
+		}
+	}
+	if err := m.Render(&w, []byte("####### seven")); err == nil {
+		t.Errorf("Render must return the parse error")
+	}
+}
+
+func Test_Options(t *testing.T) {
+	m := NewMarkdown(WithHeadingIDs(), WithUnsafe(false))
+	out, err := m.Compile("## Hello *World* 2\n\n[b](JavaScript:void)\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<h2 id=\"hello-world-2\">Hello <em>World</em> 2</h2>\n\n" +
+		"<p><a href=\"\">b</a></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Hook(t *testing.T) {
+	figure := func(c *render.Context, out []byte, block *ast.Block) []byte {
+		out = append(out, "<figure>"...)
+		out = c.Default(out, block)
+		return append(out, "<figcaption>"+render.Escape(block.Value)+"</figcaption></figure>"...)
+	}
+	ul := func(c *render.Context, out []byte, block *ast.Block) []byte {
+		out = append(out, "<ul class=\"list\">\n"...)
+		out = c.Children(out, block)
+		return append(out, "</ul>\n\n"...)
+	}
+	m := NewMarkdown(WithHook(ast.TypeImage, figure), WithHook(ast.TypeUL, ul))
+	out, err := m.Compile("![a & b](./a.png)\n\n* item\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p><figure><amp-img src=\"./a.png\" title=\"a &amp; b\" width=\"\" height=\"\"></amp-img><figcaption>a &amp; b</figcaption></figure></p>\n\n" +
+		"<ul class=\"list\">\n <li>item </li>\n</ul>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_SafeMode(t *testing.T) {
+	src := "[a](https://a.com) [b](mailto:a@b.c) [c](./c.html) [d](javascript:void) " +
+		"[e](java\tscript:void) [f](data:text/html;base64,xx) [g](tel:0120) [h](/a?b=c:d) " +
+		"![i](vbscript:x) [j](./a\"onclick=\"x)\n"
+	m := NewMarkdown(WithUnsafe(false))
+	out, err := m.Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<p><a href=\"https://a.com\">a</a> <a href=\"mailto:a@b.c\">b</a> <a href=\"./c.html\">c</a> <a href=\"\">d</a> " +
+		"<a href=\"\">e</a> <a href=\"\">f</a> <a href=\"\">g</a> <a href=\"/a?b=c:d\">h</a> " +
+		"<amp-img src=\"\" title=\"i\" width=\"\" height=\"\"></amp-img> <a href=\"./a&quot;onclick=&quot;x\">j</a></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+
+	// links are filtered by default like raw html
+	m = NewMarkdown()
+	out, err = m.Compile("[x](javascript:alert(1)) <a href=\"javascript:alert(1)\">y</a>\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected = "<p><a href=\"\">x</a>) <a>y</a></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+
+	m = NewMarkdown(WithAllowedSchemes("tel"))
+	out, err = m.Compile("[a](https://a.com) [g](TEL:0120)\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected = "<p><a href=\"\">a</a> <a href=\"TEL:0120\">g</a></p>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_RawHTML(t *testing.T) {
+	src := "<details open>\n<summary>More</summary>\n\na <kbd onclick=\"x()\" on=\"tap:x\">K</kbd> <!-- c -->\n\n</details>\n\n" +
+		"<script>\nalert(1);\n\n</script>\n" +
+		"<div style=\"x\"><a href=\"jav&#x61;script:x\">a</a><img src=x.png><iframe src=x>y</iframe></div>\n"
+	m := NewMarkdown(WithUnsafe(true))
+	out, err := m.Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<details open>\n<summary>More</summary>\n" +
+		"<p>a <kbd on=\"tap:x\">K</kbd> <!-- c --></p>\n\n" +
+		"</details>\n" +
+		"<div style=\"x\"><a href=\"jav&#x61;script:x\">a</a></div>\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+
+	// raw html is filtered by default
+	out, err = NewMarkdown().Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected = "<details open>\n<summary>More</summary>\n" +
+		"<p>a <kbd>K</kbd> </p>\n\n" +
+		"</details>\n" +
+		"<div><a>a</a></div>\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_HeadingID(t *testing.T) {
+	m := NewMarkdown(WithPermalinks())
+	out, err := m.Compile(markdown_2)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	for _, h := range []string{
+		"<h2 id=\"起動モード\">起動モード <a class=\"permalink\" href=\"#起動モード\" aria-label=\"Permalink\">#</a></h2>",
+		"<h2 id=\"singletop\">SingleTop <a class=\"permalink\" href=\"#singletop\" aria-label=\"Permalink\">#</a></h2>",
+	} {
+		if !strings.Contains(out, h) {
+			t.Errorf("Output must contain %s but %s", h, out)
+		}
+	}
+
+	out, err = m.Compile("## 起動モード\n\n## 起動モード {#launch}\n\n## 起動モード\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	expected := "<h2 id=\"起動モード\">起動モード <a class=\"permalink\" href=\"#起動モード\" aria-label=\"Permalink\">#</a></h2>\n\n" +
+		"<h2 id=\"launch\">起動モード <a class=\"permalink\" href=\"#launch\" aria-label=\"Permalink\">#</a></h2>\n\n" +
+		"<h2 id=\"起動モード-1\">起動モード <a class=\"permalink\" href=\"#起動モード-1\" aria-label=\"Permalink\">#</a></h2>\n\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
@@ -0,0 +1,69 @@
+package ast
+
+import (
+	"bytes"
+)
+
+// Lines of a blockquote are collected without '>' marker and
+// parsed as a nested document like list items.
+
+// beginBlockquote puts new blockquote at '>' to current block. The
+// blockquote is put here so that '>' at the end of src is empty quote.
+func beginBlockquote(s *parseState) {
+	quoteBlock := newBlockAt(TypeBlockquote, s.index)
+	appendChild(s.currentBlock, quoteBlock)
+	s.blockStack.Push(s.currentBlock)
+	s.currentBlock = quoteBlock
+	s.quoteValue = make([]byte, 0)
+	s.quoteMap = make(offsetMap, 0)
+}
+
+func stateReadBeginBlockquote(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		// skip one space after '>'
+		s.index++
+	}
+	s.quoteMap = s.quoteMap.add(len(s.quoteValue), s.index)
+	return stateReadQuoteText, nil
+}
+
+func stateReadQuoteText(s *parseState, char byte) (stateFunc, error) {
+	s.quoteValue = append(s.quoteValue, char)
+	s.index++
+	if char == '\n' {
+		return stateReadQuoteNewLine, nil
+	}
+	return stateReadQuoteText, nil
+}
+
+// stateReadQuoteNewLine wants '>' to continue blockquote
+func stateReadQuoteNewLine(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		s.index++
+		return stateReadQuoteNewLine, nil
+	}
+	if char == '>' {
+		s.index++
+		return stateReadBeginBlockquote, nil
+	}
+	// blockquote is ended. read this char as a new block
+	if err := endBlockquote(s); err != nil {
+		return nil, err
+	}
+	return stateReadRootBlock, nil
+}
+
+func endBlockquote(s *parseState) error {
+	quote, warnings, err := parseBlocks(s.parser, string(bytes.TrimRight(s.quoteValue, "\n")), s.lenient)
+	if err != nil {
+		return shiftError(err, s.quoteMap)
+	}
+	addWarnings(s, warnings, s.quoteMap)
+	for _, c := range quote.Children {
+		shiftPositions(c, s.quoteMap)
+	}
+	s.currentBlock.Children = quote.Children
+	s.blockStack.Clear()
+	s.currentBlock = s.root
+	return nil
+}
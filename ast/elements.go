@@ -8,15 +8,18 @@ const (
 	TypeRoot BlockType = iota + 1
 	// TypeP is paragraph
 	TypeP
-	// TypeH1 is header level 1
+	// TypeH1 is header level 1. Attributes["id"] of headings holds
+	// the explicit id written as {#id}
 	TypeH1
 	// TypeH2 is header level 2
 	TypeH2
 	// TypeUL is unordered list
 	TypeUL
-	// TypeLI is list item
+	// TypeLI is list item. Attributes["checked"] is "true" or "false"
+	// if the item is a task list item
 	TypeLI
-	// TypePreCode is pre code
+	// TypePreCode is pre code. Attributes["info"] holds the info string
+	// of the code fence and Attributes["lang"] holds its first word
 	TypePreCode
 	// TypeCode is inline code
 	TypeCode
@@ -26,15 +29,88 @@ const (
 	TypeAnchor
 	// TypeImage is image
 	TypeImage
+	// TypeH3 is header level 3
+	TypeH3
+	// TypeH4 is header level 4
+	TypeH4
+	// TypeH5 is header level 5
+	TypeH5
+	// TypeH6 is header level 6
+	TypeH6
+	// TypeOL is ordered list. Attributes["start"] holds the start number
+	TypeOL
+	// TypeEm is emphasis
+	TypeEm
+	// TypeStrong is strong emphasis
+	TypeStrong
+	// TypeDel is strikethrough. Attributes["delim"] holds "~" or "~~"
+	// of the source
+	TypeDel
+	// TypeBlockquote is blockquote
+	TypeBlockquote
+	// TypeTable is table. The first row is the header
+	TypeTable
+	// TypeTableRow is table row
+	TypeTableRow
+	// TypeTableCell is table cell. Attributes["align"] holds the alignment
+	TypeTableCell
+	// TypeHTMLBlock is raw html block. Value holds the html
+	TypeHTMLBlock
+	// TypeHTML is raw inline html such as a tag. Value holds the html
+	TypeHTML
 )
 
 // Block is an element
 type Block struct {
-	Type       BlockType
-	URL        string
-	Value      string
-	Children   []*Block
-	Attributes map[string]string
+	Type       BlockType         `json:"type"`
+	URL        string            `json:"url,omitempty"`
+	Value      string            `json:"value,omitempty"`
+	Children   []*Block          `json:"children,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Start is the position of the first byte of this block in the source
+	Start Position `json:"start"`
+	// End is the position just after the last byte of this block
+	End Position `json:"end"`
+
+	// valueMap maps offsets in Value of text to the source
+	// because newlines and container prefixes are not in Value
+	valueMap offsetMap
+}
+
+// Position is a location in the source
+type Position struct {
+	// Offset is the byte offset from the beginning of the source
+	Offset int `json:"offset"`
+	// Line is 1-based line number
+	Line int `json:"line"`
+	// Column is 1-based byte offset from the beginning of the line
+	Column int `json:"column"`
+}
+
+// IsHeading returns true if t is one of TypeH1 - TypeH6
+func IsHeading(t BlockType) bool {
+	return HeadingLevel(t) > 0
+}
+
+// HeadingLevel returns level of heading type t (1 - 6).
+// 0 is returned if t is not a heading type.
+func HeadingLevel(t BlockType) int {
+	switch t {
+	case TypeH1:
+		return 1
+	case TypeH2:
+		return 2
+	case TypeH3:
+		return 3
+	case TypeH4:
+		return 4
+	case TypeH5:
+		return 5
+	case TypeH6:
+		return 6
+	default:
+		return 0
+	}
 }
 
 func newBlock(t BlockType) *Block {
@@ -46,6 +122,12 @@ func newBlock(t BlockType) *Block {
 	}
 }
 
+func newBlockAt(t BlockType, offset int) *Block {
+	b := newBlock(t)
+	b.Start.Offset = offset
+	return b
+}
+
 type blockStack struct {
 	values []*Block
 }
@@ -0,0 +1,349 @@
+package ast
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Emphasis is resolved after all blocks are parsed. Text blocks are split
+// into delimiter runs of '*', '_' and '~', then the runs are matched
+// by the rules of CommonMark (and GFM for strikethrough). A backslash
+// before '*', '_', '~' or another backslash makes the char literal.
+
+type charClass int
+
+const (
+	charSpace charClass = iota
+	charPunct
+	charOther
+)
+
+type delimiter struct {
+	node     *Block
+	char     byte
+	count    int
+	orgCount int
+	canOpen  bool
+	canClose bool
+}
+
+// processEmphasis converts delimiter runs in the children of b (and its
+// descendants) to TypeEm, TypeStrong and TypeDel blocks.
+func processEmphasis(b *Block) {
+	if b.Type == TypePreCode {
+		return
+	}
+	for _, c := range b.Children {
+		processEmphasis(c)
+	}
+	children := make([]*Block, 0, len(b.Children))
+	start := -1
+	for i, c := range b.Children {
+		if IsInline(c.Type) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			children = append(children, resolveEmphasis(b.Children[start:i])...)
+			start = -1
+		}
+		children = append(children, c)
+	}
+	if start >= 0 {
+		children = append(children, resolveEmphasis(b.Children[start:])...)
+	}
+	b.Children = children
+}
+
+// resolveEmphasis processes a sequence of inline blocks.
+func resolveEmphasis(inlines []*Block) []*Block {
+	nodes := make([]*Block, 0, len(inlines))
+	delims := make([]*delimiter, 0)
+	escaped := false
+	for i, c := range inlines {
+		if c.Type != TypeText || !hasDelimiterChar(c.Value) {
+			nodes = append(nodes, c)
+			continue
+		}
+		v := c.Value
+		pos := 0
+		for pos < len(v) {
+			end := pos
+			if isEscapeAt(v, pos) {
+				// the escaped char is text
+				node := splitText(c, pos, pos+2)
+				node.Value = v[pos+1 : pos+2]
+				node.valueMap = offsetMap{}.add(0, valueOffset(c, pos+1))
+				nodes = append(nodes, node)
+				escaped = true
+				pos += 2
+				continue
+			}
+			if !isDelimiterChar(v[pos]) {
+				for end < len(v) && !isDelimiterChar(v[end]) && !isEscapeAt(v, end) {
+					end++
+				}
+				nodes = append(nodes, splitText(c, pos, end))
+				pos = end
+				continue
+			}
+			for end < len(v) && v[end] == v[pos] {
+				end++
+			}
+			node := splitText(c, pos, end)
+			nodes = append(nodes, node)
+			d := newDelimiter(node, classBefore(inlines, i, pos), classAfter(inlines, i, end))
+			if d.canOpen || d.canClose {
+				delims = append(delims, d)
+			}
+			pos = end
+		}
+	}
+	if len(delims) == 0 && !escaped {
+		return inlines
+	}
+	return mergeTexts(matchDelimiters(nodes, delims))
+}
+
+func newDelimiter(node *Block, before, after charClass) *delimiter {
+	d := &delimiter{
+		node:     node,
+		char:     node.Value[0],
+		count:    len(node.Value),
+		orgCount: len(node.Value),
+	}
+	leftFlanking := after != charSpace &&
+		(after != charPunct || before == charSpace || before == charPunct)
+	rightFlanking := before != charSpace &&
+		(before != charPunct || after == charSpace || after == charPunct)
+	switch d.char {
+	case '_':
+		d.canOpen = leftFlanking && (!rightFlanking || before == charPunct)
+		d.canClose = rightFlanking && (!leftFlanking || after == charPunct)
+	case '~':
+		// strikethrough uses ~ or ~~ only
+		if d.count <= 2 {
+			d.canOpen = leftFlanking
+			d.canClose = rightFlanking
+		}
+	default:
+		d.canOpen = leftFlanking
+		d.canClose = rightFlanking
+	}
+	return d
+}
+
+func matchDelimiters(nodes []*Block, delims []*delimiter) []*Block {
+	for ci := 0; ci < len(delims); ci++ {
+		closer := delims[ci]
+		if !closer.canClose {
+			continue
+		}
+		for closer.count > 0 {
+			oi := ci - 1
+			for ; oi >= 0; oi-- {
+				if canMatch(delims[oi], closer) {
+					break
+				}
+			}
+			if oi < 0 {
+				break
+			}
+			opener := delims[oi]
+			use := 1
+			if opener.char == '~' {
+				use = closer.count
+			} else if opener.count >= 2 && closer.count >= 2 {
+				use = 2
+			}
+			nodes = wrapEmphasis(nodes, opener, closer, use)
+			// delimiters between opener and closer can not be matched anymore
+			delims = append(delims[:oi+1], delims[ci:]...)
+			ci = oi + 1
+		}
+	}
+	return nodes
+}
+
+func canMatch(opener, closer *delimiter) bool {
+	if opener.char != closer.char || !opener.canOpen || opener.count == 0 {
+		return false
+	}
+	if opener.char == '~' {
+		return opener.count == closer.count
+	}
+	// rule of 3
+	if (opener.canClose || closer.canOpen) &&
+		(opener.orgCount+closer.orgCount)%3 == 0 &&
+		!(opener.orgCount%3 == 0 && closer.orgCount%3 == 0) {
+		return false
+	}
+	return true
+}
+
+// wrapEmphasis puts the blocks between opener and closer to new emphasis block
+func wrapEmphasis(nodes []*Block, opener, closer *delimiter, use int) []*Block {
+	oi := indexOfBlock(nodes, opener.node)
+	ci := indexOfBlock(nodes, closer.node)
+
+	emBlock := newBlockAt(TypeEm, opener.node.Start.Offset+opener.count-use)
+	emBlock.End.Offset = closer.node.Start.Offset + use
+	if opener.char == '~' {
+		emBlock.Type = TypeDel
+		emBlock.Attributes["delim"] = opener.node.Value[:use]
+	} else if use == 2 {
+		emBlock.Type = TypeStrong
+	}
+	emBlock.Children = append(emBlock.Children, nodes[oi+1:ci]...)
+
+	opener.count -= use
+	closer.count -= use
+	opener.node.Value = opener.node.Value[:opener.count]
+	closer.node.Value = closer.node.Value[:closer.count]
+	opener.node.End.Offset = opener.node.Start.Offset + opener.count
+	closer.node.Start.Offset += use
+
+	out := make([]*Block, 0, len(nodes))
+	out = append(out, nodes[:oi]...)
+	if opener.count > 0 {
+		out = append(out, opener.node)
+	}
+	out = append(out, emBlock)
+	if closer.count > 0 {
+		out = append(out, closer.node)
+	}
+	return append(out, nodes[ci+1:]...)
+}
+
+// mergeTexts joins adjacent text blocks split by resolveEmphasis
+func mergeTexts(nodes []*Block) []*Block {
+	out := make([]*Block, 0, len(nodes))
+	for _, n := range nodes {
+		if isEmphasis(n.Type) {
+			n.Children = mergeTexts(n.Children)
+		}
+		if n.Type == TypeText && len(out) > 0 && out[len(out)-1].Type == TypeText {
+			prev := out[len(out)-1]
+			for _, p := range n.valueMap {
+				prev.valueMap = prev.valueMap.add(len(prev.Value)+p.inner, p.outer)
+			}
+			prev.Value += n.Value
+			prev.End = n.End
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// classBefore returns the class of the char before v[pos]
+// where v is the value of inlines[i]
+func classBefore(inlines []*Block, i int, pos int) charClass {
+	v := inlines[i].Value
+	for {
+		if pos > 0 {
+			r, _ := utf8.DecodeLastRuneInString(v[:pos])
+			return classOf(r)
+		}
+		i--
+		if i < 0 {
+			// beginning of the line
+			return charSpace
+		}
+		if inlines[i].Type != TypeText {
+			return charPunct
+		}
+		v = inlines[i].Value
+		pos = len(v)
+	}
+}
+
+// classAfter returns the class of the char at v[pos]
+// where v is the value of inlines[i]
+func classAfter(inlines []*Block, i int, pos int) charClass {
+	v := inlines[i].Value
+	for {
+		if pos < len(v) {
+			r, _ := utf8.DecodeRuneInString(v[pos:])
+			return classOf(r)
+		}
+		i++
+		if i >= len(inlines) {
+			// end of the line
+			return charSpace
+		}
+		if inlines[i].Type != TypeText {
+			return charPunct
+		}
+		v = inlines[i].Value
+		pos = 0
+	}
+}
+
+func classOf(r rune) charClass {
+	if unicode.IsSpace(r) {
+		return charSpace
+	}
+	if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+		return charPunct
+	}
+	return charOther
+}
+
+func isDelimiterChar(char byte) bool {
+	return char == '*' || char == '_' || char == '~'
+}
+
+// isEscapeAt returns true if v[pos] is the backslash which escapes the next char
+func isEscapeAt(v string, pos int) bool {
+	return v[pos] == '\\' && pos+1 < len(v) && (isDelimiterChar(v[pos+1]) || v[pos+1] == '\\')
+}
+
+func hasDelimiterChar(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if isDelimiterChar(v[i]) || v[i] == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmphasis(t BlockType) bool {
+	return t == TypeEm || t == TypeStrong || t == TypeDel
+}
+
+func indexOfBlock(blocks []*Block, b *Block) int {
+	for i, c := range blocks {
+		if c == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitText returns a text block of c.Value[pos:end]
+func splitText(c *Block, pos, end int) *Block {
+	b := newBlockAt(TypeText, valueOffset(c, pos))
+	b.Value = c.Value[pos:end]
+	b.End.Offset = valueOffset(c, end-1) + 1
+	if b.End.Offset > c.End.Offset {
+		b.End.Offset = c.End.Offset
+	}
+	b.valueMap = offsetMap{}.add(0, b.Start.Offset)
+	for _, p := range c.valueMap {
+		if pos < p.inner && p.inner < end {
+			b.valueMap = b.valueMap.add(p.inner-pos, p.outer)
+		}
+	}
+	return b
+}
+
+// valueOffset returns the offset of c.Value[pos] in the source
+func valueOffset(c *Block, pos int) int {
+	if len(c.valueMap) == 0 {
+		return c.Start.Offset + pos
+	}
+	return c.valueMap.toOuter(pos)
+}
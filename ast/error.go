@@ -0,0 +1,85 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorKind is the kind of ParseError
+type ErrorKind int
+
+const (
+	// ErrInfiniteLoop means the parser stopped reading src
+	ErrInfiniteLoop ErrorKind = iota + 1
+	// ErrHeadingLevel means the heading has more than 6 '#'
+	ErrHeadingLevel
+	// ErrUnclosedLink means the link or image is not closed until
+	// the end of the paragraph or heading. It is reported only in lenient mode.
+	ErrUnclosedLink
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrInfiniteLoop:
+		return "infinite loop"
+	case ErrHeadingLevel:
+		return "heading level"
+	case ErrUnclosedLink:
+		return "unclosed link"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+const maxSnippetLen = 40
+
+// ParseError is returned by Parse with the position where
+// the document is broken.
+type ParseError struct {
+	Kind ErrorKind
+	Position
+	// Snippet is the source line from the position
+	Snippet string
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s: %q", e.Line, e.Column, e.Message, e.Snippet)
+}
+
+// newParseError returns the error at offset. Line, Column and Snippet
+// are set by locate because offset may be in the collected content
+// of the container block.
+func newParseError(kind ErrorKind, offset int, message string) *ParseError {
+	return &ParseError{
+		Kind:     kind,
+		Position: Position{Offset: offset},
+		Message:  message,
+	}
+}
+
+// shiftError converts the offset of err by m if err is ParseError
+func shiftError(err error, m offsetMap) error {
+	if e, ok := err.(*ParseError); ok {
+		e.Offset = m.toOuter(e.Offset)
+	}
+	return err
+}
+
+// locate sets Line, Column and Snippet from the offset in src
+func (e *ParseError) locate(src string) {
+	if e.Offset > len(src) {
+		e.Offset = len(src)
+	}
+	e.Position = toPosition(e.Offset, findLineStarts(src))
+	line, _ := lineAt(src, e.Offset)
+	line = strings.TrimRight(line, "\r")
+	if len(line) > maxSnippetLen {
+		n := maxSnippetLen
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		line = line[:n]
+	}
+	e.Snippet = line
+}
@@ -0,0 +1,148 @@
+package ast
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BlockParser parses a custom block at the beginning of a line.
+// Custom blocks begin after a blank line or other blocks.
+type BlockParser interface {
+	// Trigger returns the chars which may begin the block
+	Trigger() []byte
+	// ParseBlock returns the block at the beginning of src and the number
+	// of bytes read. nil is returned if src does not begin with the block.
+	// Offsets of the returned blocks are relative to src.
+	ParseBlock(src string) (*Block, int)
+}
+
+// InlineParser parses a custom inline in text. Custom inlines are
+// tried before the built-in syntax such as links and code.
+type InlineParser interface {
+	// Trigger returns the chars which may begin the inline
+	Trigger() []byte
+	// ParseInline returns the inline at the beginning of src and the number
+	// of bytes read. nil is returned if src does not begin with the inline.
+	// src ends at the end of the paragraph or heading.
+	// Offsets of the returned blocks are relative to src.
+	ParseInline(src string) (*Block, int)
+}
+
+// Extension is a set of custom syntax
+type Extension struct {
+	Blocks  []BlockParser
+	Inlines []InlineParser
+}
+
+// Parser parses markdown with extensions
+type Parser struct {
+	blocks  map[byte][]BlockParser
+	inlines map[byte][]InlineParser
+}
+
+var defaultParser = NewParser()
+
+// NewParser returns the parser with extensions. Parsers of the
+// former extension are tried first.
+func NewParser(extensions ...Extension) *Parser {
+	p := &Parser{
+		blocks:  make(map[byte][]BlockParser),
+		inlines: make(map[byte][]InlineParser),
+	}
+	for _, ext := range extensions {
+		for _, bp := range ext.Blocks {
+			for _, char := range bp.Trigger() {
+				p.blocks[char] = append(p.blocks[char], bp)
+			}
+		}
+		for _, ip := range ext.Inlines {
+			for _, char := range ip.Trigger() {
+				p.inlines[char] = append(p.inlines[char], ip)
+			}
+		}
+	}
+	return p
+}
+
+// Parse src markdown to block. The returned error is *ParseError.
+func (p *Parser) Parse(src string) (*Block, error) {
+	root, _, err := parse(p, src, false)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// ParseLenient parses src markdown to block without failing.
+// Broken constructs are read as text and reported as warnings.
+func (p *Parser) ParseLenient(src string) (*Block, []*ParseError) {
+	root, warnings, _ := parse(p, src, true)
+	return root, warnings
+}
+
+// parseBlock returns the custom block at src[i:] and the number of bytes read
+func (p *Parser) parseBlock(src string, i int) (*Block, int) {
+	for _, bp := range p.blocks[src[i]] {
+		if b, n := bp.ParseBlock(src[i:]); b != nil && n > 0 {
+			return moveBlock(b, i, n), n
+		}
+	}
+	return nil, 0
+}
+
+// parseInline returns the custom inline at src[i:] and the number of bytes read
+func (p *Parser) parseInline(src string, i int) (*Block, int) {
+	for _, ip := range p.inlines[src[i]] {
+		if b, n := ip.ParseInline(src[i:]); b != nil && n > 0 {
+			return moveBlock(b, i, n), n
+		}
+	}
+	return nil, 0
+}
+
+// moveBlock moves b returned by the extension to src[i:i+n]
+func moveBlock(b *Block, i, n int) *Block {
+	if b.End.Offset == 0 {
+		b.End.Offset = n
+	}
+	if b.Attributes == nil {
+		b.Attributes = make(map[string]string)
+	}
+	shiftPositions(b, offsetMap{}.add(0, i))
+	return b
+}
+
+var (
+	typesMu     sync.RWMutex
+	lastType    = TypeHTML
+	inlineTypes = make(map[BlockType]bool)
+)
+
+// NewBlockType returns a new BlockType for the custom syntax. name is
+// used in JSON and must be unique. inline is true if the block is
+// a part of text such as TypeAnchor.
+func NewBlockType(name string, inline bool) BlockType {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, ok := typeValues[name]; ok {
+		panic(fmt.Sprintf("block type %q is already registered", name))
+	}
+	lastType++
+	typeNames[lastType] = name
+	typeValues[name] = lastType
+	if inline {
+		inlineTypes[lastType] = true
+	}
+	return lastType
+}
+
+// IsInline returns true if t is a part of text
+func IsInline(t BlockType) bool {
+	switch t {
+	case TypeText, TypeCode, TypeAnchor, TypeImage, TypeEm, TypeStrong, TypeDel, TypeHTML:
+		return true
+	}
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	return inlineTypes[t]
+}
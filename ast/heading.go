@@ -0,0 +1,43 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Headings may end with an explicit id such as "# Title {#title}".
+// The id is moved to Attributes["id"] of the heading and removed
+// from the text.
+
+// processHeadingIDs sets the explicit ids of the headings in b
+func processHeadingIDs(b *Block) {
+	if IsHeading(b.Type) {
+		setHeadingID(b)
+		return
+	}
+	for _, c := range b.Children {
+		processHeadingIDs(c)
+	}
+}
+
+func setHeadingID(h *Block) {
+	if len(h.Children) == 0 {
+		return
+	}
+	last := h.Children[len(h.Children)-1]
+	if last.Type != TypeText {
+		return
+	}
+	value := strings.TrimRight(last.Value, " \t")
+	open := strings.LastIndex(value, "{#")
+	if open < 0 || !strings.HasSuffix(value, "}") {
+		return
+	}
+	id := value[open+2 : len(value)-1]
+	if len(id) == 0 || strings.ContainsAny(id, " \t{}") {
+		return
+	}
+	h.Attributes["id"] = id
+	text := strings.TrimRight(value[:open], " \t")
+	last.End.Offset -= len(last.Value) - len(text)
+	last.Value = text
+}
@@ -0,0 +1,334 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Raw html of CommonMark. HTML blocks begin at the beginning of a line
+// and are read as TypeHTMLBlock. Tags, comments and declarations in
+// text are read as TypeHTML. The html is kept in Value as it is.
+
+// tag names of the html blocks which may contain blank lines
+var htmlRawTags = []string{"script", "pre", "style", "textarea"}
+
+// tag names of the html blocks which end at a blank line
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true,
+	"basefont": true, "blockquote": true, "body": true, "caption": true,
+	"center": true, "col": true, "colgroup": true, "dd": true,
+	"details": true, "dialog": true, "dir": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "frame": true,
+	"frameset": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "head": true,
+	"header": true, "hr": true, "html": true, "iframe": true,
+	"legend": true, "li": true, "link": true, "main": true,
+	"menu": true, "menuitem": true, "nav": true, "noframes": true,
+	"ol": true, "optgroup": true, "option": true, "p": true,
+	"param": true, "search": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "title": true, "tr": true,
+	"track": true, "ul": true,
+}
+
+// htmlBlockKind returns the kind (1-7) of the html block which begins
+// at line by the CommonMark spec. 0 is returned for other lines.
+func htmlBlockKind(line string) int {
+	if len(line) < 2 || line[0] != '<' {
+		return 0
+	}
+	switch {
+	case strings.HasPrefix(line, "<!--"):
+		return 2
+	case strings.HasPrefix(line, "<?"):
+		return 3
+	case strings.HasPrefix(line, "<![CDATA["):
+		return 5
+	case line[1] == '!' && len(line) > 2 && isASCIILetter(line[2]):
+		return 4
+	}
+	i := 1
+	closing := line[1] == '/'
+	if closing {
+		i++
+	}
+	name, n := readTagName(line, i)
+	if n == 0 {
+		return 0
+	}
+	name = strings.ToLower(name)
+	rest := line[i+n:]
+	if !closing && isRawTag(name) && (rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '>') {
+		return 1
+	}
+	if htmlBlockTags[name] && (rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' ||
+		rest[0] == '>' || strings.HasPrefix(rest, "/>")) {
+		return 6
+	}
+	if isRawTag(name) {
+		return 0
+	}
+	// a complete tag followed by spaces only
+	n = htmlTagLen(line, 0)
+	if n == 0 || strings.Contains(line[:n], "\n") {
+		return 0
+	}
+	if rest, _ := lineAt(line, n); strings.TrimSpace(rest) != "" {
+		return 0
+	}
+	return 7
+}
+
+// htmlBlockEnd returns the end of the html block of kind at src[i:]
+func htmlBlockEnd(src string, i, kind int) int {
+	end := len(src)
+	if kind <= 5 {
+		if n := indexHTMLBlockEnd(src[i:], kind); n >= 0 {
+			line, _ := lineAt(src, i+n)
+			end = i + n + len(line)
+		}
+	} else {
+		// until a blank line
+		for pos := i; pos < len(src); {
+			line, next := lineAt(src, pos)
+			if strings.TrimSpace(line) == "" {
+				end = pos
+				break
+			}
+			pos = next
+		}
+	}
+	for end > i && src[end-1] == '\n' {
+		end--
+	}
+	return end
+}
+
+// indexHTMLBlockEnd returns the position of the end marker of the
+// html block of kind 1-5 in src. -1 is returned if it is not found.
+func indexHTMLBlockEnd(src string, kind int) int {
+	switch kind {
+	case 1:
+		end := -1
+		for _, tag := range htmlRawTags {
+			if n := indexFold(src, "</"+tag+">"); n >= 0 && (end < 0 || n < end) {
+				end = n
+			}
+		}
+		return end
+	case 2:
+		return strings.Index(src, "-->")
+	case 3:
+		return strings.Index(src, "?>")
+	case 4:
+		return strings.Index(src, ">")
+	case 5:
+		return strings.Index(src, "]]>")
+	}
+	return -1
+}
+
+// HTMLTag is the tag, comment, processing instruction, declaration
+// or CDATA section read by ReadHTMLTag
+type HTMLTag struct {
+	// Name is the lower case tag name. It is empty for comments and others
+	Name        string
+	Closing     bool
+	SelfClosing bool
+	Attrs       []HTMLAttr
+}
+
+// HTMLAttr is an attribute of the open tag
+type HTMLAttr struct {
+	// Name is the lower case attribute name
+	Name string
+	// Value is the value without quotes. Character references are kept
+	Value    string
+	HasValue bool
+}
+
+// ReadHTMLTag reads the tag, comment, processing instruction,
+// declaration or CDATA section at the beginning of src by the
+// CommonMark spec and returns its length. 0 is returned if src
+// does not begin with them.
+func ReadHTMLTag(src string) (HTMLTag, int) {
+	switch {
+	case strings.HasPrefix(src, "<!-->"):
+		return HTMLTag{}, 5
+	case strings.HasPrefix(src, "<!--->"):
+		return HTMLTag{}, 6
+	case strings.HasPrefix(src, "<!--"):
+		return HTMLTag{}, closedLen(src, 4, "-->")
+	case strings.HasPrefix(src, "<?"):
+		return HTMLTag{}, closedLen(src, 2, "?>")
+	case strings.HasPrefix(src, "<![CDATA["):
+		return HTMLTag{}, closedLen(src, 9, "]]>")
+	case strings.HasPrefix(src, "<!") && len(src) > 2 && isASCIILetter(src[2]):
+		return HTMLTag{}, closedLen(src, 2, ">")
+	case strings.HasPrefix(src, "</"):
+		name, n := readTagName(src, 2)
+		if n == 0 {
+			return HTMLTag{}, 0
+		}
+		pos := skipHTMLSpaces(src, 2+n)
+		if pos < len(src) && src[pos] == '>' {
+			return HTMLTag{Name: strings.ToLower(name), Closing: true}, pos + 1
+		}
+		return HTMLTag{}, 0
+	case strings.HasPrefix(src, "<"):
+		name, n := readTagName(src, 1)
+		if n == 0 {
+			return HTMLTag{}, 0
+		}
+		return readOpenTag(src, 1+n, HTMLTag{Name: strings.ToLower(name)})
+	}
+	return HTMLTag{}, 0
+}
+
+// htmlTagLen returns the length of the tag at src[i:]. See ReadHTMLTag.
+func htmlTagLen(src string, i int) int {
+	_, n := ReadHTMLTag(src[i:])
+	return n
+}
+
+// readOpenTag reads the attributes of the open tag from src[pos:]
+func readOpenTag(src string, pos int, tag HTMLTag) (HTMLTag, int) {
+	for {
+		next := skipHTMLSpaces(src, pos)
+		if next >= len(src) {
+			return HTMLTag{}, 0
+		}
+		if src[next] == '>' {
+			return tag, next + 1
+		}
+		if strings.HasPrefix(src[next:], "/>") {
+			tag.SelfClosing = true
+			return tag, next + 2
+		}
+		// attributes must be separated by spaces
+		if next == pos {
+			return HTMLTag{}, 0
+		}
+		attr, n := readAttr(src, next)
+		if n == 0 {
+			return HTMLTag{}, 0
+		}
+		tag.Attrs = append(tag.Attrs, attr)
+		pos = next + n
+	}
+}
+
+// readAttr reads the attribute at src[i:] and returns its length
+func readAttr(src string, i int) (HTMLAttr, int) {
+	pos := i
+	if pos >= len(src) || !isAttrNameStart(src[pos]) {
+		return HTMLAttr{}, 0
+	}
+	for pos < len(src) && isAttrNameChar(src[pos]) {
+		pos++
+	}
+	attr := HTMLAttr{Name: strings.ToLower(src[i:pos])}
+	next := skipHTMLSpaces(src, pos)
+	if next >= len(src) || src[next] != '=' {
+		return attr, pos - i
+	}
+	attr.HasValue = true
+	next = skipHTMLSpaces(src, next+1)
+	if next >= len(src) {
+		return HTMLAttr{}, 0
+	}
+	switch quote := src[next]; quote {
+	case '"', '\'':
+		end := strings.IndexByte(src[next+1:], quote)
+		if end < 0 {
+			return HTMLAttr{}, 0
+		}
+		attr.Value = src[next+1 : next+1+end]
+		return attr, next + 1 + end + 1 - i
+	}
+	end := next
+	for end < len(src) && !strings.ContainsRune(" \t\n\"'=<>`", rune(src[end])) {
+		end++
+	}
+	if end == next {
+		return HTMLAttr{}, 0
+	}
+	attr.Value = src[next:end]
+	return attr, end - i
+}
+
+// readTagName returns the tag name at src[i:] and its length
+func readTagName(src string, i int) (string, int) {
+	if i >= len(src) || !isASCIILetter(src[i]) {
+		return "", 0
+	}
+	end := i + 1
+	for end < len(src) && (isASCIILetter(src[end]) || isDigit(src[end]) || src[end] == '-') {
+		end++
+	}
+	return src[i:end], end - i
+}
+
+// closedLen returns the length of src which ends with closer
+// searched from src[from:]. 0 is returned if closer is not found.
+func closedLen(src string, from int, closer string) int {
+	n := strings.Index(src[from:], closer)
+	if n < 0 {
+		return 0
+	}
+	return from + n + len(closer)
+}
+
+// indexFold is strings.Index ignoring ASCII case
+func indexFold(src, sub string) int {
+	for i := 0; i+len(sub) <= len(src); i++ {
+		if equalFoldASCII(src[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalFoldASCII(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		if toLowerASCII(a[i]) != toLowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isRawTag(name string) bool {
+	for _, tag := range htmlRawTags {
+		if name == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func skipHTMLSpaces(src string, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func isASCIILetter(char byte) bool {
+	return ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+func isAttrNameStart(char byte) bool {
+	return isASCIILetter(char) || char == '_' || char == ':'
+}
+
+func isAttrNameChar(char byte) bool {
+	return isAttrNameStart(char) || isDigit(char) || char == '.' || char == '-'
+}
+
+func toLowerASCII(char byte) byte {
+	if 'A' <= char && char <= 'Z' {
+		return char + 'a' - 'A'
+	}
+	return char
+}
@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON schema of the tree:
+//
+//	{
+//	  "type": "p",
+//	  "url": "...",
+//	  "value": "...",
+//	  "attributes": {"name": "value"},
+//	  "start": {"offset": 0, "line": 1, "column": 1},
+//	  "end": {"offset": 5, "line": 1, "column": 6},
+//	  "children": [ ... ]
+//	}
+//
+// "url", "value", "attributes" and "children" are omitted if empty.
+
+var typeNames = map[BlockType]string{
+	TypeRoot:       "root",
+	TypeP:          "p",
+	TypeH1:         "h1",
+	TypeH2:         "h2",
+	TypeH3:         "h3",
+	TypeH4:         "h4",
+	TypeH5:         "h5",
+	TypeH6:         "h6",
+	TypeUL:         "ul",
+	TypeOL:         "ol",
+	TypeLI:         "li",
+	TypePreCode:    "pre_code",
+	TypeCode:       "code",
+	TypeText:       "text",
+	TypeAnchor:     "anchor",
+	TypeImage:      "image",
+	TypeEm:         "em",
+	TypeStrong:     "strong",
+	TypeDel:        "del",
+	TypeBlockquote: "blockquote",
+	TypeTable:      "table",
+	TypeTableRow:   "table_row",
+	TypeTableCell:  "table_cell",
+	TypeHTMLBlock:  "html_block",
+	TypeHTML:       "html",
+}
+
+var typeValues = func() map[string]BlockType {
+	values := make(map[string]BlockType, len(typeNames))
+	for t, name := range typeNames {
+		values[name] = t
+	}
+	return values
+}()
+
+func (t BlockType) String() string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("BlockType(%d)", int(t))
+}
+
+// MarshalText returns the name of t
+func (t BlockType) MarshalText() ([]byte, error) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	name, ok := typeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown block type %d", int(t))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText sets t from the name
+func (t *BlockType) UnmarshalText(text []byte) error {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	value, ok := typeValues[string(text)]
+	if !ok {
+		return fmt.Errorf("unknown block type %q", string(text))
+	}
+	*t = value
+	return nil
+}
+
+// ToJSON returns the JSON of root
+func ToJSON(root *Block) ([]byte, error) {
+	return json.Marshal(root)
+}
+
+// FromJSON returns the tree of data. Attributes of each block
+// are never nil like the tree returned by Parse.
+func FromJSON(data []byte) (*Block, error) {
+	root := &Block{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+	Walk(root, func(node *Block, entering bool) WalkStatus {
+		if entering && node.Attributes == nil {
+			node.Attributes = make(map[string]string)
+		}
+		return WalkContinue
+	})
+	return root, nil
+}
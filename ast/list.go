@@ -0,0 +1,276 @@
+package ast
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// List items are read line by line. The content of each item is
+// collected without its indentation and parsed as a nested document,
+// so that an item can hold paragraphs, code blocks and child lists.
+
+// UL
+
+func stateReadUL(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		if s.currentBlock.Type == TypeRoot {
+			// put ul
+			beginList(s, newBlockAt(TypeUL, s.index-1), s.src[s.index-1])
+		}
+		if s.currentBlock.Type == TypeUL {
+			beginListItem(s, s.index-1)
+			s.index++
+			return stateReadFirstLiToken, nil
+		}
+	}
+	// just a paragraph
+	beginParagraph(s, s.src[s.index-1:s.index])
+	// read this char as a part of text
+	return stateReadText, nil
+}
+
+// OL
+
+func stateReadOLNumber(s *parseState, char byte) (stateFunc, error) {
+	// CommonMark allows up to 9 digits
+	if isDigit(char) && len(s.numValue) < 9 {
+		s.numValue = append(s.numValue, char)
+		s.index++
+		return stateReadOLNumber, nil
+	}
+	if char == '.' || char == ')' {
+		s.numValue = append(s.numValue, char)
+		s.index++
+		return stateReadOL, nil
+	}
+	// just a paragraph
+	beginParagraph(s, string(s.numValue))
+	// read this char as a part of text
+	return stateReadText, nil
+}
+
+func stateReadOL(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		if s.currentBlock.Type == TypeRoot {
+			// put ol
+			start, _ := strconv.Atoi(string(s.numValue[:len(s.numValue)-1]))
+			olBlock := newBlockAt(TypeOL, s.index-len(s.numValue))
+			olBlock.Attributes["start"] = strconv.Itoa(start)
+			beginList(s, olBlock, s.numValue[len(s.numValue)-1])
+		}
+		if s.currentBlock.Type == TypeOL {
+			beginListItem(s, s.index-len(s.numValue))
+			s.index++
+			return stateReadFirstLiToken, nil
+		}
+	}
+	// just a paragraph
+	beginParagraph(s, string(s.numValue))
+	// read this char as a part of text
+	return stateReadText, nil
+}
+
+// LI
+
+func stateReadFirstLiToken(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		// skip
+		s.index++
+		return stateReadFirstLiToken, nil
+	}
+	// following lines must be indented to this column
+	s.liIndent = column(s)
+	if checked, ok := taskMarkerAt(s.src, s.index); ok {
+		s.liBlock.Attributes["checked"] = strconv.FormatBool(checked)
+		// skip "[x] "
+		s.index += 3
+		if s.index < s.srcLen && s.src[s.index] == ' ' {
+			s.index++
+		}
+	}
+	s.liMap = s.liMap.add(len(s.liValue), s.index)
+	return stateReadLiText, nil
+}
+
+func stateReadLiText(s *parseState, char byte) (stateFunc, error) {
+	if char == '\n' {
+		s.liValue = append(s.liValue, char)
+		s.indent = 0
+		s.blankLines = 0
+		s.index++
+		return stateReadLiNewLine, nil
+	}
+	s.liValue = append(s.liValue, char)
+	s.index++
+	return stateReadLiText, nil
+}
+
+// stateReadLiNewLine decides whether the new line belongs to
+// current item, begins next item or ends the list.
+func stateReadLiNewLine(s *parseState, char byte) (stateFunc, error) {
+	if char == ' ' {
+		s.indent++
+		s.index++
+		return stateReadLiNewLine, nil
+	}
+	if char == '\n' {
+		s.blankLines++
+		s.indent = 0
+		s.index++
+		return stateReadLiNewLine, nil
+	}
+	if s.indent >= s.liIndent {
+		// indented line is a part of current item
+		if s.blankLines > 0 {
+			s.listLoose = true
+		}
+		for i := 0; i < s.blankLines; i++ {
+			s.liValue = append(s.liValue, '\n')
+		}
+		s.liMap = s.liMap.add(len(s.liValue), s.index-(s.indent-s.liIndent))
+		for i := s.liIndent; i < s.indent; i++ {
+			s.liValue = append(s.liValue, ' ')
+		}
+		// read this char as a part of item
+		return stateReadLiText, nil
+	}
+	listType, _, marker := listMarkerAt(s.src, s.index)
+	if listType == s.currentBlock.Type && marker == s.listMarker {
+		// next item
+		if s.blankLines > 0 {
+			s.listLoose = true
+		}
+		if err := endListItem(s); err != nil {
+			return nil, err
+		}
+		s.index++
+		if listType == TypeUL {
+			return stateReadUL, nil
+		}
+		s.numValue = []byte{char}
+		return stateReadOLNumber, nil
+	}
+	// list block is ended. read this char as a new block
+	if err := endList(s); err != nil {
+		return nil, err
+	}
+	return stateReadRootBlock, nil
+}
+
+// beginList puts new list to current block. Items of the list
+// must have the same marker.
+func beginList(s *parseState, listBlock *Block, marker byte) {
+	appendChild(s.currentBlock, listBlock)
+	s.blockStack.Push(s.currentBlock)
+	s.currentBlock = listBlock
+	s.listMarker = marker
+	s.listLoose = false
+}
+
+func beginListItem(s *parseState, start int) {
+	liBlock := newBlockAt(TypeLI, start)
+	appendChild(s.currentBlock, liBlock)
+	s.liBlock = liBlock
+	s.liValue = make([]byte, 0)
+	s.liMap = make(offsetMap, 0)
+	s.liIndent = 0
+}
+
+// endListItem parses collected content of current item
+func endListItem(s *parseState) error {
+	if s.liBlock == nil {
+		return nil
+	}
+	item, warnings, err := parseBlocks(s.parser, string(bytes.TrimRight(s.liValue, "\n")), s.lenient)
+	if err != nil {
+		return shiftError(err, s.liMap)
+	}
+	addWarnings(s, warnings, s.liMap)
+	for _, c := range item.Children {
+		shiftPositions(c, s.liMap)
+	}
+	s.liBlock.Children = item.Children
+	s.liBlock = nil
+	return nil
+}
+
+func endList(s *parseState) error {
+	if err := endListItem(s); err != nil {
+		return err
+	}
+	if !s.listLoose {
+		// tight list does not wrap its items with paragraph
+		for _, liBlock := range s.currentBlock.Children {
+			unwrapParagraphs(liBlock)
+		}
+	}
+	s.blockStack.Clear()
+	s.currentBlock = s.root
+	return nil
+}
+
+func unwrapParagraphs(b *Block) {
+	children := make([]*Block, 0, len(b.Children))
+	for _, c := range b.Children {
+		if c.Type == TypeP {
+			children = append(children, c.Children...)
+		} else {
+			children = append(children, c)
+		}
+	}
+	b.Children = children
+}
+
+// listMarkerAt returns the list type if src[i:] begins with a list marker
+// followed by a space. 0 is returned if there is no list marker.
+// start is the number of the ordered list marker and marker is
+// the bullet char or the delimiter of the number.
+func listMarkerAt(src string, i int) (listType BlockType, start int, marker byte) {
+	if i+1 < len(src) && (src[i] == '*' || src[i] == '-') && src[i+1] == ' ' {
+		return TypeUL, 0, src[i]
+	}
+	j := i
+	for j < len(src) && j-i < 9 && isDigit(src[j]) {
+		j++
+	}
+	if j == i || j+1 >= len(src) {
+		return 0, 0, 0
+	}
+	if (src[j] == '.' || src[j] == ')') && src[j+1] == ' ' {
+		start, _ = strconv.Atoi(src[i:j])
+		return TypeOL, start, src[j]
+	}
+	return 0, 0, 0
+}
+
+// taskMarkerAt returns true as ok if src[i:] begins with "[ ]" or "[x]"
+// followed by a space. checked is true for "[x]".
+func taskMarkerAt(src string, i int) (checked bool, ok bool) {
+	if i+3 > len(src) || src[i] != '[' || src[i+2] != ']' {
+		return false, false
+	}
+	if i+3 < len(src) && src[i+3] != ' ' && src[i+3] != '\n' {
+		return false, false
+	}
+	switch src[i+1] {
+	case ' ':
+		return false, true
+	case 'x', 'X':
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func isList(t BlockType) bool {
+	return t == TypeUL || t == TypeOL
+}
+
+// column returns the offset of current char from the beginning of the line
+func column(s *parseState) int {
+	i := s.index
+	for i > 0 && s.src[i-1] != '\n' {
+		i--
+	}
+	return s.index - i
+}
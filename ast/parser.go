@@ -1,13 +1,14 @@
 package ast
 
 import (
-	"errors"
+	"strings"
 )
 
 type stateFunc func(s *parseState, char byte) (stateFunc, error)
 
 type parseState struct {
 	src          string
+	parser       *Parser
 	index        int
 	srcLen       int
 	root         *Block
@@ -15,19 +16,124 @@ type parseState struct {
 	blockStack   *blockStack
 
 	textValue      []byte
+	textMap        offsetMap
 	linkTitleValue []byte
 	linkURLValue   []byte
 	attrName       []byte
 	attrValue      []byte
+	numValue       []byte
+	infoValue      []byte
 
 	hCount int
+
+	// lenient mode collects errors to warnings
+	lenient  bool
+	warnings []*ParseError
+
+	// position
+	blockStart int
+	linkStart  int
+	// linkOpen is true while the link or image is read until linkEnd
+	linkOpen bool
+	linkEnd  int
+	// brackets before noLinkEnd are text as the link before them is not closed
+	noLinkEnd int
+
+	// list
+	liBlock    *Block
+	liValue    []byte
+	liIndent   int
+	indent     int
+	blankLines int
+	listMarker byte
+	listLoose  bool
+	liMap      offsetMap
+
+	// blockquote
+	quoteValue []byte
+	quoteMap   offsetMap
+
+	// table
+	rowValue    []byte
+	rowStart    int
+	tableAligns []string
 }
 
-// Parse src markdown to block
+// Parse src markdown to block. The returned error is *ParseError.
 func Parse(src string) (*Block, error) {
-	root := newBlock(TypeRoot)
-	s := &parseState{
+	return defaultParser.Parse(src)
+}
+
+// ParseLenient parses src markdown to block without failing.
+// Broken constructs are read as text and reported as warnings.
+func ParseLenient(src string) (*Block, []*ParseError) {
+	return defaultParser.ParseLenient(src)
+}
+
+func parse(p *Parser, src string, lenient bool) (*Block, []*ParseError, error) {
+	root, warnings, err := parseBlocks(p, src, lenient)
+	if err != nil {
+		if e, ok := err.(*ParseError); ok {
+			e.locate(src)
+		}
+		return nil, nil, err
+	}
+	for _, w := range warnings {
+		w.locate(src)
+	}
+	processHeadingIDs(root)
+	processEmphasis(root)
+	root.End.Offset = len(src)
+	fixPositions(root, findLineStarts(src))
+	return root, warnings, nil
+}
+
+// parseBlocks parses src as a document. This is also used
+// for the content of container blocks such as list items.
+func parseBlocks(p *Parser, src string, lenient bool) (*Block, []*ParseError, error) {
+	s := newParseState(p, src, newBlock(TypeRoot))
+	s.lenient = lenient
+	if err := run(s, stateReadRootBlock); err != nil {
+		return nil, nil, err
+	}
+	return s.root, s.warnings, nil
+}
+
+// warn returns err in strict mode. In lenient mode err is added
+// to the warnings and nil is returned so that the parser can go on.
+func warn(s *parseState, err *ParseError) error {
+	if !s.lenient {
+		return err
+	}
+	s.warnings = append(s.warnings, err)
+	return nil
+}
+
+// addWarnings adds warnings of the collected content of the container
+// block to s.
+func addWarnings(s *parseState, warnings []*ParseError, m offsetMap) {
+	for _, w := range warnings {
+		w.Offset = m.toOuter(w.Offset)
+		s.warnings = append(s.warnings, w)
+	}
+}
+
+// parseInline parses src as the inline content of parent block
+// such as table cell.
+func parseInline(p *Parser, src string, parent *Block) error {
+	s := newParseState(p, src, parent)
+	textBlock := newBlockAt(TypeText, 0)
+	appendChild(parent, textBlock)
+	s.blockStack.Push(parent)
+	s.currentBlock = textBlock
+	s.textValue = make([]byte, 0)
+	return run(s, stateReadText)
+}
+
+func newParseState(p *Parser, src string, root *Block) *parseState {
+	return &parseState{
 		src:          src,
+		parser:       p,
 		index:        0,
 		srcLen:       len(src),
 		root:         root,
@@ -35,26 +141,58 @@ func Parse(src string) (*Block, error) {
 		blockStack:   &blockStack{values: make([]*Block, 0)},
 		hCount:       0,
 	}
-	f := stateReadRootBlock
+}
+
+// run calls state functions from f until all chars are read
+func run(s *parseState, f stateFunc) error {
 	panicCounter := 0
-	for s.index < s.srcLen {
+	for s.index < s.srcLen || s.linkOpen {
+		if s.linkOpen && s.index >= s.linkEnd {
+			f = endUnclosedLink(s)
+			continue
+		}
 		panicCounter++
 		if panicCounter > s.srcLen*10 {
-			return nil, errors.New("parser may be in infinte loop")
+			err := newParseError(ErrInfiniteLoop, s.index, "parser may be in infinite loop")
+			if err := warn(s, err); err != nil {
+				return err
+			}
+			// give up parsing and read the rest as text
+			pBlock := newBlockAt(TypeP, s.index)
+			textBlock := newBlockAt(TypeText, s.index)
+			textBlock.Value = s.src[s.index:]
+			textBlock.End.Offset = s.srcLen
+			appendChild(s.root, pBlock)
+			appendChild(pBlock, textBlock)
+			s.index = s.srcLen
+			break
 		}
-		char := src[s.index]
+		char := s.src[s.index]
 
 		f2, err := f(s, char)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		f = f2
 	}
 	if s.currentBlock.Type == TypeText {
-		s.currentBlock.Value = string(s.textValue)
+		endText(s, s.index)
 	}
-	return s.root, nil
+	if isList(s.currentBlock.Type) {
+		if err := endList(s); err != nil {
+			return err
+		}
+	}
+	if s.currentBlock.Type == TypeBlockquote {
+		if err := endBlockquote(s); err != nil {
+			return err
+		}
+	}
+	if s.currentBlock.Type == TypeTable {
+		endTable(s)
+	}
+	return nil
 }
 
 func stateReadRootBlock(s *parseState, char byte) (stateFunc, error) {
@@ -63,6 +201,25 @@ func stateReadRootBlock(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadRootBlock, nil
 	}
+	s.blockStart = s.index
+	if b, n := s.parser.parseBlock(s.src, s.index); b != nil {
+		appendChild(s.currentBlock, b)
+		s.index += n
+		return stateReadRootBlock, nil
+	}
+	if kind := htmlBlockKind(s.src[s.index:]); kind > 0 {
+		end := htmlBlockEnd(s.src, s.index, kind)
+		htmlBlock := newBlockAt(TypeHTMLBlock, s.index)
+		htmlBlock.Value = s.src[s.index:end]
+		htmlBlock.End.Offset = end
+		appendChild(s.currentBlock, htmlBlock)
+		s.index = end
+		return stateReadRootBlock, nil
+	}
+	if isTableAt(s.src, s.index) {
+		beginTable(s)
+		return stateReadTableLine, nil
+	}
 	if char == '#' {
 		s.hCount = 1
 		s.index++
@@ -72,14 +229,32 @@ func stateReadRootBlock(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadUL, nil
 	}
+	if isDigit(char) {
+		s.numValue = []byte{char}
+		s.index++
+		return stateReadOLNumber, nil
+	}
+	if char == '>' {
+		beginBlockquote(s)
+		s.index++
+		return stateReadBeginBlockquote, nil
+	}
 	if char == '`' {
 		s.hCount = 1
 		s.index++
 		return stateReadBeginPreCode, nil
 	}
 	// paragraph block
-	pBlock := newBlock(TypeP)
-	textBlock := newBlock(TypeText)
+	beginParagraph(s, "")
+	// read this char as a part of text
+	return stateReadText, nil
+}
+
+// beginParagraph puts new paragraph to current block.
+// prefix is used as the beginning of the text.
+func beginParagraph(s *parseState, prefix string) {
+	pBlock := newBlockAt(TypeP, s.index-len(prefix))
+	textBlock := newBlockAt(TypeText, s.index-len(prefix))
 	appendChild(s.currentBlock, pBlock)
 	appendChild(pBlock, textBlock)
 	s.blockStack.Push(s.currentBlock)
@@ -87,21 +262,27 @@ func stateReadRootBlock(s *parseState, char byte) (stateFunc, error) {
 
 	s.currentBlock = textBlock
 	s.textValue = make([]byte, 0)
-	// read this char as a part of text
-	return stateReadText, nil
+	appendText(s, s.index-len(prefix), prefix)
 }
 
 func stateReadHn(s *parseState, char byte) (stateFunc, error) {
 	if char == '#' {
-		if s.hCount > 2 {
-			return nil, errors.New("Cannot support level 2 header")
+		if s.hCount >= 6 {
+			err := newParseError(ErrHeadingLevel, s.blockStart, "Cannot support level 7 header")
+			if err := warn(s, err); err != nil {
+				return nil, err
+			}
+			// read the line as a paragraph
+			s.index = s.blockStart
+			beginParagraph(s, "")
+			return stateReadText, nil
 		}
 		s.hCount++
 		s.index++
 		return stateReadHn, nil
 	}
-	hBlock := newBlock(toHnType(s.hCount))
-	textBlock := newBlock(TypeText)
+	hBlock := newBlockAt(toHnType(s.hCount), s.blockStart)
+	textBlock := newBlockAt(TypeText, s.index)
 
 	appendChild(s.currentBlock, hBlock)
 	appendChild(hBlock, textBlock)
@@ -123,15 +304,15 @@ func stateFindFirstText(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateFindFirstText, nil
 	}
+	s.currentBlock.Start.Offset = s.index
 	return stateReadText, nil
 }
 
 func stateReadText(s *parseState, char byte) (stateFunc, error) {
 	if char == '\n' {
 		parentBlock := s.blockStack.Top()
-		if parentBlock.Type == TypeH1 ||
-			parentBlock.Type == TypeH2 {
-			s.currentBlock.Value = string(s.textValue)
+		if IsHeading(parentBlock.Type) {
+			endText(s, s.index)
 			parentBlock = s.blockStack.Pop() // h block is ended
 			parentBlock = s.blockStack.Pop() // parent of h block
 			s.currentBlock = parentBlock
@@ -139,84 +320,191 @@ func stateReadText(s *parseState, char byte) (stateFunc, error) {
 			s.index++
 			return stateReadRootBlock, nil
 		}
-		if parentBlock.Type == TypeLI {
-			s.currentBlock.Value = string(s.textValue)
-			parentBlock = s.blockStack.Pop() // li block is ended
-			parentBlock = s.blockStack.Pop() // parent of li
-			s.currentBlock = parentBlock
-			s.index++
-			return stateReadNextLiToken, nil
-		}
 		// ignore \n, if \n again, we must close current block
 		s.index++
 		return stateReadTextNewLine, nil
 	}
-	if char == '[' {
+	// custom inlines are tried before the built-in syntax
+	if b, n := customInline(s, char); b != nil {
+		endText(s, s.index)
+		parentBlock := s.blockStack.Top()
+		appendChild(parentBlock, b)
+
+		s.index += n
+		textBlock := newBlockAt(TypeText, s.index)
+		appendChild(parentBlock, textBlock)
+		s.currentBlock = textBlock
+		s.textValue = make([]byte, 0)
+		return stateReadText, nil
+	}
+	if char == '[' && s.index >= s.noLinkEnd {
 		s.linkTitleValue = make([]byte, 0)
-		s.index++
+		beginLink(s)
 		return stateReadLinkTitle, nil
 	}
-	if char == '!' {
-		s.index++
+	if char == '!' && s.index >= s.noLinkEnd && strings.HasPrefix(s.src[s.index+1:], "[") {
+		beginLink(s)
 		return stateReadBeginImageToken, nil
 	}
 	if char == '`' {
-		s.currentBlock.Value = string(s.textValue)
-
+		value, n := codeSpanAt(s.src[:inlineEnd(s)], s.index)
+		if n == 0 {
+			// backticks without the closing run are text
+			end := backtickRunEnd(s.src, s.index)
+			appendText(s, s.index, s.src[s.index:end])
+			s.index = end
+			return stateReadText, nil
+		}
 		parentBlock := s.blockStack.Top()
-		codeBlock := newBlock(TypeCode)
+		if parentBlock.Type == TypeP && len(parentBlock.Children) == 1 && len(s.textValue) == 0 {
+			// the block begins with the code
+			parentBlock.Children = parentBlock.Children[:0]
+		} else {
+			endText(s, s.index)
+		}
+		codeBlock := newBlockAt(TypeCode, s.index)
+		codeBlock.Value = value
+		codeBlock.End.Offset = s.index + n
 		appendChild(parentBlock, codeBlock)
-		s.currentBlock = codeBlock
 
+		s.index += n
+		textBlock := newBlockAt(TypeText, s.index)
+		appendChild(parentBlock, textBlock)
+		s.currentBlock = textBlock
 		s.textValue = make([]byte, 0)
-		s.index++
-		return stateReadInlineCode, nil
-	}
-	// escape
-	if char == '<' {
-		s.textValue = appendStr(s.textValue, "&lt;")
-		s.index++
 		return stateReadText, nil
 	}
-	if char == '>' {
-		s.textValue = appendStr(s.textValue, "&gt;")
-		s.index++
-		return stateReadText, nil
-	}
-	if char == '&' {
-		s.textValue = appendStr(s.textValue, "&amp;")
-		s.index++
-		return stateReadText, nil
-	}
-	if char == '"' {
-		s.textValue = appendStr(s.textValue, "&quot;")
-		s.index++
-		return stateReadText, nil
+	if char == '<' {
+		if n := htmlTagLen(s.src[:inlineEnd(s)], s.index); n > 0 {
+			endText(s, s.index)
+			parentBlock := s.blockStack.Top()
+			htmlBlock := newBlockAt(TypeHTML, s.index)
+			htmlBlock.Value = s.src[s.index : s.index+n]
+			htmlBlock.End.Offset = s.index + n
+			appendChild(parentBlock, htmlBlock)
+
+			s.index += n
+			textBlock := newBlockAt(TypeText, s.index)
+			appendChild(parentBlock, textBlock)
+			s.currentBlock = textBlock
+			s.textValue = make([]byte, 0)
+			return stateReadText, nil
+		}
 	}
-	s.textValue = append(s.textValue, char)
+	appendText(s, s.index, s.src[s.index:s.index+1])
 	s.index++
 	return stateReadText, nil
 }
 
+// customInline returns the custom inline at s.index. It may span
+// the paragraph or heading only like the built-in inlines.
+func customInline(s *parseState, char byte) (*Block, int) {
+	if len(s.parser.inlines[char]) == 0 {
+		return nil, 0
+	}
+	return s.parser.parseInline(s.src[:inlineEnd(s)], s.index)
+}
+
 func stateReadTextNewLine(s *parseState, char byte) (stateFunc, error) {
 	if char == '\n' {
 		// close all block
-		s.currentBlock.Value = string(s.textValue)
+		endText(s, s.index-1)
 		s.blockStack.Clear()
 		s.currentBlock = s.root
 		s.index++
 		return stateReadRootBlock, nil
 	}
-	s.textValue = append(s.textValue, char)
-	s.index++
+	if interruptsParagraph(s.src, s.index) {
+		// close all block and read this line as a new block
+		endText(s, s.index-1)
+		s.blockStack.Clear()
+		s.currentBlock = s.root
+		return stateReadRootBlock, nil
+	}
+	// read this char as a part of text
 	return stateReadText, nil
 }
 
+// inlineEnd returns the end of the text which the inline at s.index
+// may span. Headings end at the end of the line and paragraphs end
+// at a blank line or a line which begins a new block.
+func inlineEnd(s *parseState) int {
+	line, next := lineAt(s.src, s.index)
+	if IsHeading(s.blockStack.Top().Type) {
+		return s.index + len(line)
+	}
+	for next < s.srcLen {
+		line, after := lineAt(s.src, next)
+		if strings.TrimSpace(line) == "" || interruptsParagraph(s.src, next) {
+			return next
+		}
+		next = after
+	}
+	return s.srcLen
+}
+
+// interruptsParagraph returns true if the line at src[i:]
+// begins a new block without a blank line.
+func interruptsParagraph(src string, i int) bool {
+	for n := 0; n < 3 && i < len(src) && src[i] == ' '; n++ {
+		i++
+	}
+	if strings.HasPrefix(src[i:], "```") || strings.HasPrefix(src[i:], ">") {
+		return true
+	}
+	// html blocks except the kind 7 interrupt paragraph
+	if kind := htmlBlockKind(src[i:]); kind > 0 && kind < 7 {
+		return true
+	}
+	listType, start, _ := listMarkerAt(src, i)
+	// ordered list must begin with 1 to interrupt paragraph
+	return listType == TypeUL || (listType == TypeOL && start == 1)
+}
+
 // link
 
+// beginLink begins the link or image at s.index. It is read until
+// the end of the inline so that blocks after it are not lost.
+func beginLink(s *parseState) {
+	s.linkStart = s.index
+	s.linkEnd = inlineEnd(s)
+	s.linkOpen = true
+	s.index++
+}
+
+// endUnclosedLink reads the bracket of the link or image which is not
+// closed until s.linkEnd as text, and reads the rest again.
+func endUnclosedLink(s *parseState) stateFunc {
+	if s.currentBlock.Type == TypeImage {
+		// remove the image whose attributes are not closed
+		parentBlock := s.blockStack.Top()
+		parentBlock.Children = parentBlock.Children[:len(parentBlock.Children)-1]
+		s.currentBlock = parentBlock.Children[len(parentBlock.Children)-1]
+	}
+	if s.lenient {
+		s.warnings = append(s.warnings, newParseError(ErrUnclosedLink, s.linkStart, "link is not closed"))
+	}
+	s.linkOpen = false
+	// the brackets after it are not closed too
+	s.noLinkEnd = s.linkEnd
+	appendText(s, s.linkStart, s.src[s.linkStart:s.linkStart+1])
+	s.index = s.linkStart + 1
+	return stateReadText
+}
+
+// linkAsText reads the link or image without URL as text
+func linkAsText(s *parseState, prefix string) {
+	s.linkOpen = false
+	appendText(s, s.linkStart, prefix+"["+string(s.linkTitleValue)+"]")
+}
+
 func stateReadLinkTitle(s *parseState, char byte) (stateFunc, error) {
 	if char == ']' {
 		s.index++
+		if s.index >= s.linkEnd {
+			linkAsText(s, "")
+			return stateReadText, nil
+		}
 		return stateReadLinkURLBeginToken, nil
 	}
 	s.linkTitleValue = append(s.linkTitleValue, char)
@@ -231,25 +519,25 @@ func stateReadLinkURLBeginToken(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadLinkURL, nil
 	}
-	s.textValue = append(s.textValue, '[')
-	s.textValue = appendStr(s.textValue, string(s.linkTitleValue))
-	s.textValue = append(s.textValue, ']')
+	linkAsText(s, "")
 	// read current char as a part of text
 	return stateReadText, nil
 }
 
 func stateReadLinkURL(s *parseState, char byte) (stateFunc, error) {
 	if char == ')' {
-		s.currentBlock.Value = string(s.textValue)
+		s.linkOpen = false
+		endText(s, s.linkStart)
 
 		parentBlock := s.blockStack.Top()
-		linkBlock := newBlock(TypeAnchor)
+		linkBlock := newBlockAt(TypeAnchor, s.linkStart)
 		linkBlock.Value = string(s.linkTitleValue)
 		linkBlock.URL = string(s.linkURLValue)
+		linkBlock.End.Offset = s.index + 1
 		appendChild(parentBlock, linkBlock)
 
 		// next block
-		textBlock := newBlock(TypeText)
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(parentBlock, textBlock)
 		s.currentBlock = textBlock
 
@@ -271,7 +559,8 @@ func stateReadBeginImageToken(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadImageTitle, nil
 	}
-	s.textValue = append(s.textValue, '!')
+	s.linkOpen = false
+	appendText(s, s.linkStart, "!")
 	// read this character as a part of text
 	return stateReadText, nil
 }
@@ -279,6 +568,10 @@ func stateReadBeginImageToken(s *parseState, char byte) (stateFunc, error) {
 func stateReadImageTitle(s *parseState, char byte) (stateFunc, error) {
 	if char == ']' {
 		s.index++
+		if s.index >= s.linkEnd {
+			linkAsText(s, "!")
+			return stateReadText, nil
+		}
 		return stateReadImageURLBeginToken, nil
 	}
 	s.linkTitleValue = append(s.linkTitleValue, char)
@@ -293,26 +586,25 @@ func stateReadImageURLBeginToken(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadImageURL, nil
 	}
-	s.textValue = append(s.textValue, '!')
-	s.textValue = append(s.textValue, '[')
-	s.textValue = appendStr(s.textValue, string(s.linkTitleValue))
-	s.textValue = append(s.textValue, ']')
+	linkAsText(s, "!")
 	// read current char as a part of text
 	return stateReadText, nil
 }
 
 func stateReadImageURL(s *parseState, char byte) (stateFunc, error) {
 	if char == ')' {
-		s.currentBlock.Value = string(s.textValue)
+		s.linkOpen = false
+		endText(s, s.linkStart)
 
 		parentBlock := s.blockStack.Top()
-		imageBlock := newBlock(TypeImage)
+		imageBlock := newBlockAt(TypeImage, s.linkStart)
 		imageBlock.Value = string(s.linkTitleValue)
 		imageBlock.URL = string(s.linkURLValue)
+		imageBlock.End.Offset = s.index + 1
 		appendChild(parentBlock, imageBlock)
 
 		// next block
-		textBlock := newBlock(TypeText)
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(parentBlock, textBlock)
 		s.currentBlock = textBlock
 
@@ -321,10 +613,10 @@ func stateReadImageURL(s *parseState, char byte) (stateFunc, error) {
 		return stateReadText, nil
 	}
 	if char == ' ' {
-		s.currentBlock.Value = string(s.textValue)
+		endText(s, s.linkStart)
 
 		parentBlock := s.blockStack.Top()
-		imageBlock := newBlock(TypeImage)
+		imageBlock := newBlockAt(TypeImage, s.linkStart)
 		imageBlock.Value = string(s.linkTitleValue)
 		imageBlock.URL = string(s.linkURLValue)
 		appendChild(parentBlock, imageBlock)
@@ -345,9 +637,11 @@ func stateReadBeginImageAttr(s *parseState, char byte) (stateFunc, error) {
 		return stateReadBeginImageAttr, nil
 	}
 	if char == ')' {
+		s.linkOpen = false
+		s.currentBlock.End.Offset = s.index + 1
 		parentBlock := s.blockStack.Top()
 		// next block
-		textBlock := newBlock(TypeText)
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(parentBlock, textBlock)
 		s.currentBlock = textBlock
 
@@ -374,9 +668,11 @@ func stateReadImageAttrName(s *parseState, char byte) (stateFunc, error) {
 		attrValue := ""
 		s.currentBlock.Attributes[attrName] = attrValue
 
+		s.linkOpen = false
+		s.currentBlock.End.Offset = s.index + 1
 		parentBlock := s.blockStack.Top()
 		// next block
-		textBlock := newBlock(TypeText)
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(parentBlock, textBlock)
 		s.currentBlock = textBlock
 
@@ -407,9 +703,11 @@ func stateReadImageAttrValue(s *parseState, char byte) (stateFunc, error) {
 		attrValue := string(s.attrValue)
 		s.currentBlock.Attributes[attrName] = attrValue
 
+		s.linkOpen = false
+		s.currentBlock.End.Offset = s.index + 1
 		parentBlock := s.blockStack.Top()
 		// next block
-		textBlock := newBlock(TypeText)
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(parentBlock, textBlock)
 		s.currentBlock = textBlock
 
@@ -422,93 +720,6 @@ func stateReadImageAttrValue(s *parseState, char byte) (stateFunc, error) {
 	return stateReadImageAttrValue, nil
 }
 
-// UL
-
-func stateReadUL(s *parseState, char byte) (stateFunc, error) {
-	if char == ' ' {
-		if s.currentBlock.Type == TypeRoot {
-			// put ul
-			ulBlock := newBlock(TypeUL)
-			appendChild(s.currentBlock, ulBlock)
-			s.blockStack.Push(s.currentBlock)
-			s.currentBlock = ulBlock
-			s.index++
-			return stateReadFirstLiToken, nil
-		}
-		if s.currentBlock.Type == TypeUL {
-			s.index++
-			return stateReadFirstLiToken, nil
-		}
-	}
-	// just a paragraph
-	pBlock := newBlock(TypeP)
-	textBlock := newBlock(TypeText)
-	appendChild(s.currentBlock, pBlock)
-	appendChild(pBlock, textBlock)
-	s.blockStack.Push(s.currentBlock)
-	s.blockStack.Push(pBlock)
-
-	s.currentBlock = textBlock
-	s.textValue = make([]byte, 0)
-	s.textValue = append(s.textValue, '*')
-	// read this char as a part of text
-	return stateReadText, nil
-}
-
-func stateReadFirstLiToken(s *parseState, char byte) (stateFunc, error) {
-	if char == ' ' {
-		// skip
-		s.index++
-		return stateReadFirstLiToken, nil
-	}
-	// make li
-	liBlock := newBlock(TypeLI)
-	textBlock := newBlock(TypeText)
-	appendChild(s.currentBlock, liBlock)
-	appendChild(liBlock, textBlock)
-	s.blockStack.Push(s.currentBlock)
-	s.blockStack.Push(liBlock)
-
-	s.currentBlock = textBlock
-	s.textValue = make([]byte, 0)
-	// read this char as a part of text
-	return stateReadText, nil
-}
-
-func stateReadNextLiToken(s *parseState, char byte) (stateFunc, error) {
-	if char == ' ' {
-		// skip
-		s.index++
-		return stateReadNextLiToken, nil
-	}
-	if char == '*' || char == '-' {
-		s.index++
-		return stateReadFirstLiToken, nil
-	}
-	if char == '\n' {
-		// ul block is ended. clear all stack
-		s.blockStack.Clear()
-		s.currentBlock = s.root
-		s.index++
-		return stateReadRootBlock, nil
-	}
-	// new paragraph begins
-	s.blockStack.Clear()
-	s.currentBlock = s.root
-
-	pBlock := newBlock(TypeP)
-	textBlock := newBlock(TypeText)
-	appendChild(s.currentBlock, pBlock)
-	appendChild(pBlock, textBlock)
-	s.blockStack.Push(s.currentBlock)
-	s.blockStack.Push(pBlock)
-
-	s.currentBlock = textBlock
-	s.textValue = make([]byte, 0)
-	// read this char as a part of text
-	return stateReadText, nil
-}
-
 // pre code
 func stateReadBeginPreCode(s *parseState, char byte) (stateFunc, error) {
 	if char == '`' {
@@ -517,32 +728,26 @@ func stateReadBeginPreCode(s *parseState, char byte) (stateFunc, error) {
 			s.index++
 			return stateReadBeginPreCode, nil
 		}
+		s.infoValue = make([]byte, 0)
 		s.index++
 		return stateReadBeginPreCodeNewLine, nil
 	}
-	if s.hCount == 1 {
-		// p with code
-		pBlock := newBlock(TypeP)
-		codeBlock := newBlock(TypeCode)
-		appendChild(s.currentBlock, pBlock)
-		appendChild(pBlock, codeBlock)
-		s.blockStack.Push(s.currentBlock)
-		s.blockStack.Push(pBlock)
-
-		s.currentBlock = codeBlock
-		s.textValue = make([]byte, 0)
-		// read this char as a part of text
-		return stateReadInlineCode, nil
-	}
-	s.textValue = make([]byte, 0)
+	// p beginning with inline code. read the backticks again as text
+	s.index = s.blockStart
+	beginParagraph(s, "")
 	return stateReadText, nil
 }
 
 func stateReadBeginPreCodeNewLine(s *parseState, char byte) (stateFunc, error) {
 	if char == '\n' {
 		// begin pre code
-		preCodeBlock := newBlock(TypePreCode)
-		textBlock := newBlock(TypeText)
+		preCodeBlock := newBlockAt(TypePreCode, s.blockStart)
+		info := strings.TrimSpace(string(s.infoValue))
+		if len(info) > 0 {
+			preCodeBlock.Attributes["info"] = info
+			preCodeBlock.Attributes["lang"] = strings.Fields(info)[0]
+		}
+		textBlock := newBlockAt(TypeText, s.index+1)
 		appendChild(s.currentBlock, preCodeBlock)
 		appendChild(preCodeBlock, textBlock)
 		s.blockStack.Push(s.currentBlock)
@@ -553,7 +758,8 @@ func stateReadBeginPreCodeNewLine(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadPreCodeText, nil
 	}
-	// TODO support language
+	// info string
+	s.infoValue = append(s.infoValue, char)
 	s.index++
 	return stateReadBeginPreCodeNewLine, nil
 }
@@ -564,27 +770,6 @@ func stateReadPreCodeText(s *parseState, char byte) (stateFunc, error) {
 		s.index++
 		return stateReadEndPreCode, nil
 	}
-	// escape
-	if char == '<' {
-		s.textValue = appendStr(s.textValue, "&lt;")
-		s.index++
-		return stateReadPreCodeText, nil
-	}
-	if char == '>' {
-		s.textValue = appendStr(s.textValue, "&gt;")
-		s.index++
-		return stateReadPreCodeText, nil
-	}
-	if char == '&' {
-		s.textValue = appendStr(s.textValue, "&amp;")
-		s.index++
-		return stateReadPreCodeText, nil
-	}
-	if char == '"' {
-		s.textValue = appendStr(s.textValue, "&quot;")
-		s.index++
-		return stateReadPreCodeText, nil
-	}
 	s.textValue = append(s.textValue, char)
 	s.index++
 	return stateReadPreCodeText, nil
@@ -598,10 +783,11 @@ func stateReadEndPreCode(s *parseState, char byte) (stateFunc, error) {
 			return stateReadEndPreCode, nil
 		}
 		// end of pre code
-		s.currentBlock.Value = string(s.textValue)
+		endText(s, s.index-2)
 
 		parentBlock := s.blockStack.Pop() // preCode
-		parentBlock = s.blockStack.Pop()  // parent of preCode
+		parentBlock.End.Offset = s.index + 1
+		parentBlock = s.blockStack.Pop() // parent of preCode
 		s.currentBlock = parentBlock
 		s.index++
 		return stateReadRootBlock, nil
@@ -614,22 +800,62 @@ func stateReadEndPreCode(s *parseState, char byte) (stateFunc, error) {
 
 // inline code
 
-func stateReadInlineCode(s *parseState, char byte) (stateFunc, error) {
-	if char == '`' {
-		s.currentBlock.Value = string(s.textValue)
+// codeSpanAt returns the value of the code span at src[i:] and its
+// length. The code span ends with the backtick run of the same length
+// as the beginning. 0 is returned if it is not closed.
+func codeSpanAt(src string, i int) (string, int) {
+	open := backtickRunEnd(src, i) - i
+	for pos := i + open; pos < len(src); {
+		n := strings.IndexByte(src[pos:], '`')
+		if n < 0 {
+			break
+		}
+		start := pos + n
+		end := backtickRunEnd(src, start)
+		if end-start == open {
+			value := src[i+open : start]
+			// one space on both sides is removed
+			if len(value) >= 2 && value[0] == ' ' && value[len(value)-1] == ' ' && strings.Trim(value, " ") != "" {
+				value = value[1 : len(value)-1]
+			}
+			return value, end - i
+		}
+		pos = end
+	}
+	return "", 0
+}
 
-		textBlock := newBlock(TypeText)
-		parentBlock := s.blockStack.Top()
-		appendChild(parentBlock, textBlock)
-		s.currentBlock = textBlock
+// backtickRunEnd returns the end of the backticks at src[i:]
+func backtickRunEnd(src string, i int) int {
+	for i < len(src) && src[i] == '`' {
+		i++
+	}
+	return i
+}
 
-		s.textValue = make([]byte, 0)
-		s.index++
-		return stateReadText, nil
+// appendText appends str read at offset to the collected text. The
+// offset is recorded where the text is not contiguous in the source.
+func appendText(s *parseState, offset int, str string) {
+	if len(s.textValue) == 0 {
+		s.textMap = nil
 	}
-	s.textValue = append(s.textValue, char)
-	s.index++
-	return stateReadInlineCode, nil
+	if len(s.textMap) == 0 || s.textMap.toOuter(len(s.textValue)) != offset {
+		s.textMap = s.textMap.add(len(s.textValue), offset)
+	}
+	s.textValue = appendStr(s.textValue, str)
+}
+
+// endText sets collected text to current text block which ends at end
+func endText(s *parseState, end int) {
+	s.currentBlock.Value = string(s.textValue)
+	s.currentBlock.End.Offset = end
+	if len(s.textValue) > 0 {
+		s.currentBlock.valueMap = s.textMap
+	}
+}
+
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
 }
 
 func toHnType(level int) BlockType {
@@ -638,6 +864,14 @@ func toHnType(level int) BlockType {
 		return TypeH1
 	case 2:
 		return TypeH2
+	case 3:
+		return TypeH3
+	case 4:
+		return TypeH4
+	case 5:
+		return TypeH5
+	case 6:
+		return TypeH6
 	default:
 		return TypeH1
 	}
@@ -1,7 +1,11 @@
 package ast
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -104,6 +108,96 @@ const src13 = `# inline code block
 
 const src14 = `# [Detail](./detail.html)`
 
+const src15 = `### Level3
+
+#### Level4
+
+##### Level5
+###### Level6
+`
+
+const src16 = `####### Level7`
+
+const src17 = `Steps
+
+ 3. first
+ 4. [second](./second.html)
+ 5. third
+
+10 apples
+`
+
+const src19 = `Tree
+ * parent
+   * child1
+   * child2
+     1. grandchild
+ * sibling
+`
+
+const src20 = "- item\n" +
+	"\n" +
+	"  continued\n" +
+	"\n" +
+	"  ```\n" +
+	"  code\n" +
+	"  ```\n" +
+	"- next\n"
+
+const src21 = `This is *em*, **strong**, _em_ and ~~del~~.
+
+***nested*** snake_case_name 2 * 3`
+
+const src22 = `Release note
+> ## v1.0
+> * fixed
+>
+> > nested
+after`
+
+const src23 = "```go {title=main.go}\n" +
+	"package main\n" +
+	"```\n"
+
+const src24 = `API
+
+| Name | Type | Description |
+|:-----|:----:|------------:|
+| ` + "`id`" + ` | int | **unique** id |
+| a \| b | string
+after`
+
+const src25 = `TODO
+ - [ ] write test
+ - [x] [implement](./impl.html)
+ - [link](./link.html)
+ - [X]
+`
+
+const src26 = `# Title
+
+Some *em* text
+
+ * item
+ * [x] done
+
+> quote
+
+| a | b |
+|---|---|
+| 1 | 2 |
+`
+
+const src27 = `# Title
+
+ * item
+ * ####### seven
+`
+
+const src18 = `1) ordered
+ - unordered
+2020.10 is not a list`
+
 func Test_Stack(t *testing.T) {
 	stack := &blockStack{values: make([]*Block, 0)}
 	stack.Push(newBlock(TypeRoot))
@@ -276,7 +370,6 @@ func Test_4(t *testing.T) {
 }
 
 func Test_5(t *testing.T) {
-	// language is not supported..
 	out, err := Parse(src5)
 	if err != nil {
 		t.Errorf("Parse error : %s", err)
@@ -297,6 +390,9 @@ func Test_5(t *testing.T) {
 
 	pBlock = out.Children[1]
 	checkBlock(t, pBlock, TypePreCode, 1)
+	if pBlock.Attributes["lang"] != "java" {
+		t.Errorf("lang must be java but %s", pBlock.Attributes["lang"])
+	}
 	pText = pBlock.Children[0]
 	checkTextBlock(t, pText, "package main\n\nfunc main() {\n}\n")
 }
@@ -391,7 +487,7 @@ func Test_8(t *testing.T) {
 	checkBlock(t, pBlock, TypeP, 1)
 
 	pText = pBlock.Children[0]
-	checkTextBlock(t, pText, "Welcome")
+	checkTextBlock(t, pText, "Welcome!")
 }
 
 func Test_9(t *testing.T) {
@@ -484,20 +580,27 @@ func Test_12(t *testing.T) {
 	// root
 	//    |- h1
 	//    |   |- text
+	//    |   |- html
+	//    |   |- text
 	//    |- PreCode
 	//        |- text
 	checkBlock(t, out, TypeRoot, 2)
 
 	h1Block := out.Children[0]
-	checkBlock(t, h1Block, TypeH1, 1)
+	checkBlock(t, h1Block, TypeH1, 3)
 
-	h1Text := h1Block.Children[0]
-	checkTextBlock(t, h1Text, "&lt;h1&gt; &quot;tag&quot;")
+	h1HTML := h1Block.Children[1]
+	checkBlock(t, h1HTML, TypeHTML, 0)
+	if h1HTML.Value != "<h1>" {
+		t.Errorf("Value must be <h1> but %s", h1HTML.Value)
+	}
+	h1Text := h1Block.Children[2]
+	checkTextBlock(t, h1Text, " \"tag\"")
 
 	preBlock := out.Children[1]
 	checkBlock(t, preBlock, TypePreCode, 1)
 	preText := preBlock.Children[0]
-	checkTextBlock(t, preText, "&lt;LinearLayout android:id=&quot;@+id/abc&quot;/&gt;\n")
+	checkTextBlock(t, preText, "<LinearLayout android:id=\"@+id/abc\"/>\n")
 }
 
 func Test_13(t *testing.T) {
@@ -554,6 +657,1032 @@ func Test_14(t *testing.T) {
 	checkTextBlock(t, h1Text, "")
 }
 
+func Test_15(t *testing.T) {
+	out, err := Parse(src15)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- h3
+	//    |- h4
+	//    |- h5
+	//    |- h6
+	checkBlock(t, out, TypeRoot, 4)
+
+	hBlock := out.Children[0]
+	checkBlock(t, hBlock, TypeH3, 1)
+	checkTextBlock(t, hBlock.Children[0], "Level3")
+
+	hBlock = out.Children[1]
+	checkBlock(t, hBlock, TypeH4, 1)
+	checkTextBlock(t, hBlock.Children[0], "Level4")
+
+	hBlock = out.Children[2]
+	checkBlock(t, hBlock, TypeH5, 1)
+	checkTextBlock(t, hBlock.Children[0], "Level5")
+
+	hBlock = out.Children[3]
+	checkBlock(t, hBlock, TypeH6, 1)
+	checkTextBlock(t, hBlock.Children[0], "Level6")
+	if HeadingLevel(hBlock.Type) != 6 {
+		t.Errorf("Level must be 6 but %d", HeadingLevel(hBlock.Type))
+	}
+}
+
+func Test_16(t *testing.T) {
+	_, err := Parse(src16)
+	if err == nil {
+		t.Errorf("Level 7 header must be error")
+		return
+	}
+}
+
+func Test_17(t *testing.T) {
+	out, err := Parse(src17)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- p
+	//    |- ol
+	//    |   |- li
+	//    |   |- li
+	//    |   |- li
+	//    |- p
+	checkBlock(t, out, TypeRoot, 3)
+
+	olBlock := out.Children[1]
+	checkBlock(t, olBlock, TypeOL, 3)
+	if olBlock.Attributes["start"] != "3" {
+		t.Errorf("start must be 3 but %s", olBlock.Attributes["start"])
+	}
+	liBlock := olBlock.Children[0]
+	checkBlock(t, liBlock, TypeLI, 1)
+	checkTextBlock(t, liBlock.Children[0], "first")
+	liBlock = olBlock.Children[1]
+	checkBlock(t, liBlock, TypeLI, 3)
+	checkAnchorBlock(t, liBlock.Children[1], "second", "./second.html")
+	liBlock = olBlock.Children[2]
+	checkBlock(t, liBlock, TypeLI, 1)
+	checkTextBlock(t, liBlock.Children[0], "third")
+
+	pBlock := out.Children[2]
+	checkBlock(t, pBlock, TypeP, 1)
+	checkTextBlock(t, pBlock.Children[0], "10 apples")
+}
+
+func Test_18(t *testing.T) {
+	out, err := Parse(src18)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- ol
+	//    |   |- li
+	//    |- ul
+	//    |   |- li
+	//    |- p
+	checkBlock(t, out, TypeRoot, 3)
+
+	olBlock := out.Children[0]
+	checkBlock(t, olBlock, TypeOL, 1)
+	if olBlock.Attributes["start"] != "1" {
+		t.Errorf("start must be 1 but %s", olBlock.Attributes["start"])
+	}
+	checkTextBlock(t, olBlock.Children[0].Children[0], "ordered")
+
+	ulBlock := out.Children[1]
+	checkBlock(t, ulBlock, TypeUL, 1)
+	checkTextBlock(t, ulBlock.Children[0].Children[0], "unordered")
+
+	pBlock := out.Children[2]
+	checkBlock(t, pBlock, TypeP, 1)
+	checkTextBlock(t, pBlock.Children[0], "2020.10 is not a list")
+}
+
+func Test_19(t *testing.T) {
+	out, err := Parse(src19)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- p
+	//    |- ul
+	//        |- li
+	//        |   |- text
+	//        |   |- ul
+	//        |       |- li
+	//        |       |- li
+	//        |           |- text
+	//        |           |- ol
+	//        |               |- li
+	//        |- li
+	checkBlock(t, out, TypeRoot, 2)
+
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 1)
+	checkTextBlock(t, pBlock.Children[0], "Tree")
+
+	ulBlock := out.Children[1]
+	checkBlock(t, ulBlock, TypeUL, 2)
+
+	liBlock := ulBlock.Children[0]
+	checkBlock(t, liBlock, TypeLI, 2)
+	checkTextBlock(t, liBlock.Children[0], "parent")
+
+	childUL := liBlock.Children[1]
+	checkBlock(t, childUL, TypeUL, 2)
+	checkBlock(t, childUL.Children[0], TypeLI, 1)
+	checkTextBlock(t, childUL.Children[0].Children[0], "child1")
+
+	liBlock = childUL.Children[1]
+	checkBlock(t, liBlock, TypeLI, 2)
+	checkTextBlock(t, liBlock.Children[0], "child2")
+	childOL := liBlock.Children[1]
+	checkBlock(t, childOL, TypeOL, 1)
+	checkTextBlock(t, childOL.Children[0].Children[0], "grandchild")
+
+	liBlock = ulBlock.Children[1]
+	checkBlock(t, liBlock, TypeLI, 1)
+	checkTextBlock(t, liBlock.Children[0], "sibling")
+}
+
+func Test_20(t *testing.T) {
+	out, err := Parse(src20)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- ul
+	//        |- li
+	//        |   |- p
+	//        |   |- p
+	//        |   |- preCode
+	//        |- li
+	//            |- p
+	checkBlock(t, out, TypeRoot, 1)
+
+	ulBlock := out.Children[0]
+	checkBlock(t, ulBlock, TypeUL, 2)
+
+	liBlock := ulBlock.Children[0]
+	checkBlock(t, liBlock, TypeLI, 3)
+	checkBlock(t, liBlock.Children[0], TypeP, 1)
+	checkTextBlock(t, liBlock.Children[0].Children[0], "item")
+	checkBlock(t, liBlock.Children[1], TypeP, 1)
+	checkTextBlock(t, liBlock.Children[1].Children[0], "continued")
+	checkBlock(t, liBlock.Children[2], TypePreCode, 1)
+	checkTextBlock(t, liBlock.Children[2].Children[0], "code\n")
+
+	liBlock = ulBlock.Children[1]
+	checkBlock(t, liBlock, TypeLI, 1)
+	checkBlock(t, liBlock.Children[0], TypeP, 1)
+}
+
+func Test_21(t *testing.T) {
+	out, err := Parse(src21)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- p
+	//    |   |- text
+	//    |   |- em
+	//    |   |- text
+	//    |   |- strong
+	//    |   |- text
+	//    |   |- em
+	//    |   |- text
+	//    |   |- del
+	//    |   |- text
+	//    |- p
+	//        |- em
+	//        |   |- strong
+	//        |- text
+	checkBlock(t, out, TypeRoot, 2)
+
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 9)
+	checkTextBlock(t, pBlock.Children[0], "This is ")
+	checkEmphasisBlock(t, pBlock.Children[1], TypeEm, "em")
+	checkTextBlock(t, pBlock.Children[2], ", ")
+	checkEmphasisBlock(t, pBlock.Children[3], TypeStrong, "strong")
+	checkTextBlock(t, pBlock.Children[4], ", ")
+	checkEmphasisBlock(t, pBlock.Children[5], TypeEm, "em")
+	checkTextBlock(t, pBlock.Children[6], " and ")
+	checkEmphasisBlock(t, pBlock.Children[7], TypeDel, "del")
+	checkTextBlock(t, pBlock.Children[8], ".")
+
+	pBlock = out.Children[1]
+	checkBlock(t, pBlock, TypeP, 2)
+	emBlock := pBlock.Children[0]
+	checkBlock(t, emBlock, TypeEm, 1)
+	checkEmphasisBlock(t, emBlock.Children[0], TypeStrong, "nested")
+	checkTextBlock(t, pBlock.Children[1], " snake_case_name 2 * 3")
+}
+
+func Test_EmphasisEscape(t *testing.T) {
+	out, err := Parse("\\*a* \\\\*b* ~c~ x\\y")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 5)
+	checkTextBlock(t, pBlock.Children[0], "*a* \\")
+	checkEmphasisBlock(t, pBlock.Children[1], TypeEm, "b")
+	checkTextBlock(t, pBlock.Children[2], " ")
+	checkEmphasisBlock(t, pBlock.Children[3], TypeDel, "c")
+	if delim := pBlock.Children[3].Attributes["delim"]; delim != "~" {
+		t.Errorf("delim must be ~ but %s", delim)
+	}
+	checkTextBlock(t, pBlock.Children[4], " x\\y")
+}
+
+func Test_EmptyBlockquote(t *testing.T) {
+	out, err := Parse("- >\n\n>")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 2)
+	checkBlock(t, out.Children[0].Children[0], TypeLI, 1)
+	checkBlock(t, out.Children[0].Children[0].Children[0], TypeBlockquote, 0)
+	checkBlock(t, out.Children[1], TypeBlockquote, 0)
+}
+
+func Test_22(t *testing.T) {
+	out, err := Parse(src22)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- p
+	//    |- blockquote
+	//    |   |- h2
+	//    |   |- ul
+	//    |   |- blockquote
+	//    |       |- p
+	//    |- p
+	checkBlock(t, out, TypeRoot, 3)
+
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 1)
+	checkTextBlock(t, pBlock.Children[0], "Release note")
+
+	quoteBlock := out.Children[1]
+	checkBlock(t, quoteBlock, TypeBlockquote, 3)
+	checkBlock(t, quoteBlock.Children[0], TypeH2, 1)
+	checkTextBlock(t, quoteBlock.Children[0].Children[0], "v1.0")
+	checkBlock(t, quoteBlock.Children[1], TypeUL, 1)
+
+	nestedBlock := quoteBlock.Children[2]
+	checkBlock(t, nestedBlock, TypeBlockquote, 1)
+	checkBlock(t, nestedBlock.Children[0], TypeP, 1)
+	checkTextBlock(t, nestedBlock.Children[0].Children[0], "nested")
+
+	pBlock = out.Children[2]
+	checkBlock(t, pBlock, TypeP, 1)
+	checkTextBlock(t, pBlock.Children[0], "after")
+}
+
+func Test_23(t *testing.T) {
+	out, err := Parse(src23)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 1)
+
+	preBlock := out.Children[0]
+	checkBlock(t, preBlock, TypePreCode, 1)
+	if preBlock.Attributes["info"] != "go {title=main.go}" {
+		t.Errorf("info must be 'go {title=main.go}' but %s", preBlock.Attributes["info"])
+	}
+	if preBlock.Attributes["lang"] != "go" {
+		t.Errorf("lang must be go but %s", preBlock.Attributes["lang"])
+	}
+	checkTextBlock(t, preBlock.Children[0], "package main\n")
+}
+
+func Test_24(t *testing.T) {
+	out, err := Parse(src24)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- p
+	//    |- table
+	//    |   |- row
+	//    |   |- row
+	//    |   |- row
+	//    |   |- row
+	checkBlock(t, out, TypeRoot, 2)
+
+	tableBlock := out.Children[1]
+	checkBlock(t, tableBlock, TypeTable, 4)
+
+	aligns := []string{"left", "center", "right"}
+	header := tableBlock.Children[0]
+	checkBlock(t, header, TypeTableRow, 3)
+	for i, cell := range header.Children {
+		checkBlock(t, cell, TypeTableCell, 1)
+		if cell.Attributes["align"] != aligns[i] {
+			t.Errorf("align must be %s but %s", aligns[i], cell.Attributes["align"])
+		}
+	}
+	checkTextBlock(t, header.Children[2].Children[0], "Description")
+
+	row := tableBlock.Children[1]
+	checkBlock(t, row, TypeTableRow, 3)
+	checkBlock(t, row.Children[0], TypeTableCell, 3)
+	checkInlineCodeBlock(t, row.Children[0].Children[1], "id")
+	checkBlock(t, row.Children[2], TypeTableCell, 2)
+	checkEmphasisBlock(t, row.Children[2].Children[0], TypeStrong, "unique")
+
+	row = tableBlock.Children[2]
+	checkBlock(t, row, TypeTableRow, 3)
+	checkTextBlock(t, row.Children[0].Children[0], "a | b")
+	checkTextBlock(t, row.Children[1].Children[0], "string")
+	checkBlock(t, row.Children[2], TypeTableCell, 0)
+	if row.Children[2].Attributes["align"] != "right" {
+		t.Errorf("align must be right but %s", row.Children[2].Attributes["align"])
+	}
+
+	// GFM continues table until blank line
+	row = tableBlock.Children[3]
+	checkBlock(t, row, TypeTableRow, 3)
+	checkTextBlock(t, row.Children[0].Children[0], "after")
+}
+
+func Test_25(t *testing.T) {
+	out, err := Parse(src25)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 2)
+
+	ulBlock := out.Children[1]
+	checkBlock(t, ulBlock, TypeUL, 4)
+
+	liBlock := ulBlock.Children[0]
+	checkBlock(t, liBlock, TypeLI, 1)
+	if liBlock.Attributes["checked"] != "false" {
+		t.Errorf("checked must be false but %s", liBlock.Attributes["checked"])
+	}
+	checkTextBlock(t, liBlock.Children[0], "write test")
+
+	liBlock = ulBlock.Children[1]
+	checkBlock(t, liBlock, TypeLI, 3)
+	if liBlock.Attributes["checked"] != "true" {
+		t.Errorf("checked must be true but %s", liBlock.Attributes["checked"])
+	}
+	checkAnchorBlock(t, liBlock.Children[1], "implement", "./impl.html")
+
+	liBlock = ulBlock.Children[2]
+	checkBlock(t, liBlock, TypeLI, 3)
+	if _, ok := liBlock.Attributes["checked"]; ok {
+		t.Errorf("link must not be a task")
+	}
+
+	liBlock = ulBlock.Children[3]
+	checkBlock(t, liBlock, TypeLI, 0)
+	if liBlock.Attributes["checked"] != "true" {
+		t.Errorf("checked must be true but %s", liBlock.Attributes["checked"])
+	}
+}
+
+func Test_26(t *testing.T) {
+	out, err := Parse(src26)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 5)
+	checkPosition(t, out.Children[0], Position{0, 1, 1}, Position{7, 1, 8})
+	checkPosition(t, out.Children[0].Children[0], Position{2, 1, 3}, Position{7, 1, 8})
+
+	pBlock := out.Children[1]
+	checkPosition(t, pBlock, Position{9, 3, 1}, Position{23, 3, 15})
+	checkPosition(t, pBlock.Children[1], Position{14, 3, 6}, Position{18, 3, 10})
+	checkPosition(t, pBlock.Children[1].Children[0], Position{15, 3, 7}, Position{17, 3, 9})
+
+	ulBlock := out.Children[2]
+	checkPosition(t, ulBlock, Position{26, 5, 2}, Position{44, 6, 12})
+	checkPosition(t, ulBlock.Children[0], Position{26, 5, 2}, Position{32, 5, 8})
+	checkPosition(t, ulBlock.Children[1].Children[0], Position{40, 6, 8}, Position{44, 6, 12})
+
+	quoteBlock := out.Children[3]
+	checkPosition(t, quoteBlock.Children[0], Position{48, 8, 3}, Position{53, 8, 8})
+
+	rowBlock := out.Children[4].Children[1]
+	checkPosition(t, rowBlock, Position{75, 12, 1}, Position{84, 12, 10})
+	checkPosition(t, rowBlock.Children[1], Position{81, 12, 7}, Position{82, 12, 8})
+}
+
+func Test_EmphasisPosition(t *testing.T) {
+	out, err := Parse("a\nb *c\nd* e\n\n> x\n> y **z**\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 3)
+	checkPosition(t, pBlock.Children[0], Position{0, 1, 1}, Position{4, 2, 3})
+	emBlock := pBlock.Children[1]
+	checkEmphasisBlock(t, emBlock, TypeEm, "cd")
+	checkPosition(t, emBlock, Position{4, 2, 3}, Position{9, 3, 3})
+	checkPosition(t, emBlock.Children[0], Position{5, 2, 4}, Position{8, 3, 2})
+	checkPosition(t, pBlock.Children[2], Position{9, 3, 3}, Position{11, 3, 5})
+
+	pBlock = out.Children[1].Children[0]
+	checkBlock(t, pBlock, TypeP, 2)
+	checkPosition(t, pBlock.Children[0], Position{15, 5, 3}, Position{21, 6, 5})
+	strongBlock := pBlock.Children[1]
+	checkEmphasisBlock(t, strongBlock, TypeStrong, "z")
+	checkPosition(t, strongBlock, Position{21, 6, 5}, Position{26, 6, 10})
+	checkPosition(t, strongBlock.Children[0], Position{23, 6, 7}, Position{24, 6, 8})
+}
+
+func Test_27(t *testing.T) {
+	_, err := Parse(src27)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("error must be ParseError but %v", err)
+		return
+	}
+	if parseErr.Kind != ErrHeadingLevel {
+		t.Errorf("Kind must be %s but %s", ErrHeadingLevel, parseErr.Kind)
+	}
+	if parseErr.Position != (Position{20, 4, 4}) {
+		t.Errorf("Position must be {20 4 4} but %v", parseErr.Position)
+	}
+	if parseErr.Snippet != "####### seven" {
+		t.Errorf("Snippet must be '####### seven' but %s", parseErr.Snippet)
+	}
+}
+
+func Test_28(t *testing.T) {
+	out, warnings := ParseLenient(src27)
+	checkBlock(t, out, TypeRoot, 2)
+	if len(warnings) != 1 {
+		t.Errorf("warnings must have 1 but %d", len(warnings))
+		return
+	}
+	if warnings[0].Kind != ErrHeadingLevel || warnings[0].Position != (Position{20, 4, 4}) {
+		t.Errorf("warning must be heading level at {20 4 4} but %s %v", warnings[0].Kind, warnings[0].Position)
+	}
+	liBlock := out.Children[1].Children[1]
+	checkBlock(t, liBlock, TypeLI, 1)
+	checkTextBlock(t, liBlock.Children[0], "####### seven")
+}
+
+func Test_LenientUnclosed(t *testing.T) {
+	out, warnings := ParseLenient("see [note")
+	checkBlock(t, out, TypeRoot, 1)
+	checkTextBlock(t, out.Children[0].Children[0], "see [note")
+	if len(warnings) != 1 || warnings[0].Kind != ErrUnclosedLink || warnings[0].Position != (Position{4, 1, 5}) {
+		t.Errorf("warning must be unclosed link at {4 1 5} but %v", warnings)
+	}
+
+	out, warnings = ParseLenient("a ![img](./a.png")
+	checkTextBlock(t, out.Children[0].Children[0], "a ![img](./a.png")
+	if len(warnings) != 1 || warnings[0].Kind != ErrUnclosedLink {
+		t.Errorf("warning must be unclosed link but %v", warnings)
+	}
+
+	// the unclosed link ends at the end of the paragraph
+	out, warnings = ParseLenient("a [b\n\n# Heading\n\ntext")
+	checkBlock(t, out, TypeRoot, 3)
+	checkTextBlock(t, out.Children[0].Children[0], "a [b")
+	checkBlock(t, out.Children[1], TypeH1, 1)
+	checkTextBlock(t, out.Children[1].Children[0], "Heading")
+	checkTextBlock(t, out.Children[2].Children[0], "text")
+	if len(warnings) != 1 || warnings[0].Kind != ErrUnclosedLink || warnings[0].Position != (Position{2, 1, 3}) {
+		t.Errorf("warning must be unclosed link at {2 1 3} but %v", warnings)
+	}
+
+	// inlines after the bracket are read
+	out, _ = ParseLenient("# a ![b `c`\n\n![d](e f")
+	checkBlock(t, out, TypeRoot, 2)
+	checkTextBlock(t, out.Children[0].Children[0], "a ![b ")
+	checkInlineCodeBlock(t, out.Children[0].Children[1], "c")
+	checkTextBlock(t, out.Children[1].Children[0], "![d](e f")
+
+	// the text without URL is not warned
+	out, warnings = ParseLenient("see [x]!")
+	checkTextBlock(t, out.Children[0].Children[0], "see [x]!")
+	if len(warnings) != 0 {
+		t.Errorf("warnings must be empty but %v", warnings)
+	}
+
+	// unclosed code is text
+	out, warnings = ParseLenient("a `code")
+	checkTextBlock(t, out.Children[0].Children[0], "a `code")
+	if len(warnings) != 0 {
+		t.Errorf("warnings must be empty but %v", warnings)
+	}
+
+	// the paragraph may begin with double backticks
+	out, _ = ParseLenient("``foo`` bar")
+	checkBlock(t, out.Children[0], TypeP, 2)
+	checkInlineCodeBlock(t, out.Children[0].Children[0], "foo")
+	checkTextBlock(t, out.Children[0].Children[1], " bar")
+}
+
+func Test_LenientNoPanic(t *testing.T) {
+	chars := []string{"`", "``", "```", "[", "]", "(", ")", "!", "*", "_", "~", "#", "-", "1.", ">",
+		"|", "<", "<b>", "</b>", "<!--", "-->", "{#", "}", " ", "  ", "\n", "\n\n", "a", "b c", "="}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		src := ""
+		for n := r.Intn(20); n >= 0; n-- {
+			src += chars[r.Intn(len(chars))]
+		}
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					t.Errorf("ParseLenient(%q) panics : %v", src, err)
+				}
+			}()
+			if out, _ := ParseLenient(src); out == nil {
+				t.Errorf("ParseLenient(%q) returns nil", src)
+			}
+		}()
+	}
+}
+
+func Test_Walk(t *testing.T) {
+	out, err := Parse("# Title\n\n* [a](./a.html)\n* [b](./b.html)\n\n[c](./c.html)\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	urls := make([]string, 0)
+	Walk(out, func(node *Block, entering bool) WalkStatus {
+		if !entering {
+			return WalkContinue
+		}
+		if node.Type == TypeUL {
+			return WalkSkipChildren
+		}
+		if node.Type == TypeAnchor {
+			urls = append(urls, node.URL)
+		}
+		return WalkContinue
+	})
+	if fmt.Sprint(urls) != "[./c.html]" {
+		t.Errorf("urls must be [./c.html] but %v", urls)
+	}
+
+	count := 0
+	status := Walk(out, func(node *Block, entering bool) WalkStatus {
+		if entering && node.Type == TypeAnchor {
+			count++
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if status != WalkStop || count != 1 {
+		t.Errorf("Walk must stop at the first anchor but %d %d", status, count)
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth int
+}
+
+func (v *depthVisitor) Enter(node *Block) WalkStatus {
+	v.depth++
+	if v.depth > v.maxDepth {
+		v.maxDepth = v.depth
+	}
+	return WalkContinue
+}
+
+func (v *depthVisitor) Leave(node *Block) WalkStatus {
+	v.depth--
+	return WalkContinue
+}
+
+func Test_WalkVisitor(t *testing.T) {
+	out, err := Parse("* a\n  * b\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	v := &depthVisitor{}
+	WalkVisitor(out, v)
+	// root > ul > li > ul > li > text
+	if v.depth != 0 || v.maxDepth != 6 {
+		t.Errorf("depth must be 0 and maxDepth must be 6 but %d %d", v.depth, v.maxDepth)
+	}
+}
+
+func Test_JSON(t *testing.T) {
+	out, err := Parse("# Title\n\n* [a](./a.html)\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	data, err := ToJSON(out.Children[0])
+	if err != nil {
+		t.Errorf("ToJSON error : %s", err)
+		return
+	}
+	expected := `{"type":"h1","children":[{"type":"text","value":"Title",` +
+		`"start":{"offset":2,"line":1,"column":3},"end":{"offset":7,"line":1,"column":8}}],` +
+		`"start":{"offset":0,"line":1,"column":1},"end":{"offset":7,"line":1,"column":8}}`
+	if string(data) != expected {
+		t.Errorf("JSON must be %s but %s", expected, data)
+	}
+
+	data, err = ToJSON(out)
+	if err != nil {
+		t.Errorf("ToJSON error : %s", err)
+		return
+	}
+	tree, err := FromJSON(data)
+	if err != nil {
+		t.Errorf("FromJSON error : %s", err)
+		return
+	}
+	checkBlock(t, tree, TypeRoot, 2)
+	checkBlock(t, tree.Children[1], TypeUL, 1)
+	checkAnchorBlock(t, tree.Children[1].Children[0].Children[1], "a", "./a.html")
+	if tree.Children[1].Children[0].Start != (Position{9, 3, 1}) {
+		t.Errorf("Start must be {9 3 1} but %v", tree.Children[1].Children[0].Start)
+	}
+	if tree.Attributes == nil {
+		t.Errorf("Attributes must not be nil")
+	}
+
+	if _, err := FromJSON([]byte(`{"type":"unknown"}`)); err == nil {
+		t.Errorf("unknown type must be error")
+	}
+}
+
+func Test_ParseBytes(t *testing.T) {
+	srcs := []string{src1, src2, src3, src4, src5, src6, src7, src8, src9, src10,
+		src11, src12, src13, src14, src15, src16, src17, src18, src19, src20,
+		src21, src22, src23, src24, src25, src26}
+	for i, src := range srcs {
+		out, err := Parse(src)
+		if err != nil {
+			if _, err := ParseBytes([]byte(src)); err == nil {
+				t.Errorf("ParseBytes(src%d) must fail", i+1)
+			}
+			continue
+		}
+		expected, _ := ToJSON(out)
+
+		out, err = ParseBytes([]byte(src))
+		if err != nil {
+			t.Errorf("ParseBytes error : %s", err)
+			continue
+		}
+		data, _ := ToJSON(out)
+		if !bytes.Equal(data, expected) {
+			t.Errorf("ParseBytes(src%d) must be %s but %s", i+1, expected, data)
+		}
+
+		out, err = ParseReader(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("ParseReader error : %s", err)
+			continue
+		}
+		data, _ = ToJSON(out)
+		if !bytes.Equal(data, expected) {
+			t.Errorf("ParseReader(src%d) must be %s but %s", i+1, expected, data)
+		}
+	}
+
+	_, err := ParseReader(strings.NewReader(src27))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Position != (Position{20, 4, 4}) {
+		t.Errorf("error must be at {20 4 4} but %v", err)
+	}
+}
+
+func Test_ParseBytesSameAsParse(t *testing.T) {
+	srcs := []string{
+		"a\n  \nb",
+		"a [b\n  \nc](d)\n",
+		"a [b\n\n# Heading\n\ntext",
+		"x\n\n````` \n\n}<b>```\n\n```\n\n<!--",
+		"``````\n  \n``` ````\n\nb(",
+		"<!-- a\n\nb --> c\n\nd",
+		"<pre>\n\na\n\n</pre>\n\nb",
+	}
+	chars := []string{"`", "```", "[", "]", "(", ")", "!", "*", "#", "- ", "> ", "1.", "|",
+		"<pre>", "</pre>", "<!--", "-->", "<div>", " ", "    ", "\n", "\n\n", "\n  \n", "a", "b c"}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		src := ""
+		for n := r.Intn(20); n >= 0; n-- {
+			src += chars[r.Intn(len(chars))]
+		}
+		srcs = append(srcs, src)
+	}
+	for _, src := range srcs {
+		out, err := Parse(src)
+		if err != nil {
+			continue
+		}
+		expected, _ := ToJSON(out)
+		out, err = ParseBytes([]byte(src))
+		if err != nil {
+			t.Errorf("ParseBytes(%q) error : %s", src, err)
+			continue
+		}
+		if data, _ := ToJSON(out); !bytes.Equal(data, expected) {
+			t.Errorf("ParseBytes(%q) must be %s but %s", src, expected, data)
+		}
+	}
+}
+
+var (
+	typeCallout = NewBlockType("callout", false)
+	typeProduct = NewBlockType("product", true)
+)
+
+type calloutParser struct{}
+
+func (calloutParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (calloutParser) ParseBlock(src string) (*Block, int) {
+	if !strings.HasPrefix(src, ":::") {
+		return nil, 0
+	}
+	head, next := lineAt(src, 0)
+	end := strings.Index(src[next:], "\n:::")
+	if end < 0 {
+		return nil, 0
+	}
+	content, err := Parse(src[next : next+end])
+	if err != nil {
+		return nil, 0
+	}
+	b := newBlock(typeCallout)
+	b.Attributes["kind"] = head[3:]
+	b.Children = content.Children
+	_, n := lineAt(src, next+end+1)
+	return b, n
+}
+
+type productParser struct{}
+
+func (productParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+func (productParser) ParseInline(src string) (*Block, int) {
+	end := strings.IndexByte(src, ']')
+	if !strings.HasPrefix(src, "@[") || end < 0 {
+		return nil, 0
+	}
+	b := newBlock(typeProduct)
+	b.Value = src[2:end]
+	return b, end + 1
+}
+
+var typeWikiLink = NewBlockType("wiki_link", true)
+
+// wikiLinkParser parses [[Page]] which begins like a link
+type wikiLinkParser struct{}
+
+func (wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (wikiLinkParser) ParseInline(src string) (*Block, int) {
+	end := strings.Index(src, "]]")
+	if !strings.HasPrefix(src, "[[") || end < 0 {
+		return nil, 0
+	}
+	b := newBlock(typeWikiLink)
+	b.Value = src[2:end]
+	return b, end + 2
+}
+
+func Test_ExtensionTrigger(t *testing.T) {
+	p := NewParser(Extension{Inlines: []InlineParser{wikiLinkParser{}}})
+	out, err := p.Parse("[[Home]] and [[Help]] [link](./a.html)\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 7)
+	for i, name := range []string{"Home", "Help"} {
+		b := pBlock.Children[i*2+1]
+		checkBlock(t, b, typeWikiLink, 0)
+		if b.Value != name {
+			t.Errorf("wiki link must be %s but %s", name, b.Value)
+		}
+	}
+	checkBlock(t, pBlock.Children[5], TypeAnchor, 0)
+}
+
+func Test_ExtensionInlineEnd(t *testing.T) {
+	p := NewParser(Extension{Inlines: []InlineParser{wikiLinkParser{}}})
+	out, err := p.Parse("a [[Home\n\nPage]] b\n\n# [[c\nd]]\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 4)
+	texts := []string{"a [[Home", "Page]] b", "[[c", "d]]"}
+	for i, text := range texts {
+		checkBlock(t, out.Children[i], out.Children[i].Type, 1)
+		checkTextBlock(t, out.Children[i].Children[0], text)
+	}
+	checkBlock(t, out.Children[2], TypeH1, 1)
+}
+
+func Test_Extension(t *testing.T) {
+	p := NewParser(Extension{
+		Blocks:  []BlockParser{calloutParser{}},
+		Inlines: []InlineParser{productParser{}},
+	})
+	out, err := p.Parse("Buy @[sku1] *now*\n\n:::note\nfirst\n\nsecond\n:::\n\nafter @ [x]\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 3)
+
+	pBlock := out.Children[0]
+	checkBlock(t, pBlock, TypeP, 4)
+	checkTextBlock(t, pBlock.Children[0], "Buy ")
+	checkBlock(t, pBlock.Children[1], typeProduct, 0)
+	if pBlock.Children[1].Value != "sku1" || pBlock.Children[1].Start != (Position{4, 1, 5}) {
+		t.Errorf("product must be sku1 at {4 1 5} but %s %v", pBlock.Children[1].Value, pBlock.Children[1].Start)
+	}
+	checkEmphasisBlock(t, pBlock.Children[3], TypeEm, "now")
+
+	calloutBlock := out.Children[1]
+	checkBlock(t, calloutBlock, typeCallout, 2)
+	if calloutBlock.Attributes["kind"] != "note" || calloutBlock.Start != (Position{19, 3, 1}) {
+		t.Errorf("callout must be note at {19 3 1} but %s %v", calloutBlock.Attributes["kind"], calloutBlock.Start)
+	}
+
+	checkBlock(t, out.Children[2], TypeP, 1)
+	checkTextBlock(t, out.Children[2].Children[0], "after @ [x]")
+
+	data, err := ToJSON(calloutBlock)
+	if err != nil || !strings.HasPrefix(string(data), `{"type":"callout"`) {
+		t.Errorf("JSON must begin with callout type but %s %v", data, err)
+	}
+}
+
+func Test_HTML(t *testing.T) {
+	src := "<details>\n<summary>More</summary>\n\ntext<br>a <kbd\nclass=\"k\">K</kbd> <!-- c -->\n" +
+		"<script>\nf();\n\n</script>\n" +
+		"<custom-tag>\n\na < b <x y\n"
+	out, err := Parse(src)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	// root
+	//    |- HTMLBlock
+	//    |- P
+	//    |   |- text, html, text, html, text, html, text, html
+	//    |- HTMLBlock
+	//    |- HTMLBlock
+	//    |- P
+	checkBlock(t, out, TypeRoot, 5)
+	checkBlock(t, out.Children[0], TypeHTMLBlock, 0)
+	if v := out.Children[0].Value; v != "<details>\n<summary>More</summary>" {
+		t.Errorf("html block must be details but %q", v)
+	}
+	pBlock := out.Children[1]
+	checkBlock(t, pBlock, TypeP, 9)
+	values := []string{"<br>", "<kbd\nclass=\"k\">", "</kbd>", "<!-- c -->"}
+	for i, v := range values {
+		b := pBlock.Children[i*2+1]
+		checkBlock(t, b, TypeHTML, 0)
+		if b.Value != v {
+			t.Errorf("html must be %q but %q", v, b.Value)
+		}
+	}
+	checkPosition(t, pBlock.Children[3], Position{45, 4, 11}, Position{60, 5, 11})
+
+	// blank lines do not end the script block
+	if v := out.Children[2].Value; v != "<script>\nf();\n\n</script>" {
+		t.Errorf("html block must be script but %q", v)
+	}
+	if v := out.Children[3].Value; v != "<custom-tag>" {
+		t.Errorf("html block must be custom-tag but %q", v)
+	}
+	checkBlock(t, out.Children[4], TypeP, 1)
+	checkTextBlock(t, out.Children[4].Children[0], "a < b <x y")
+
+	// chunks are not split in the script block
+	out2, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Errorf("ParseBytes error : %s", err)
+		return
+	}
+	checkBlock(t, out2, TypeRoot, 5)
+	if v := out2.Children[2].Value; v != out.Children[2].Value {
+		t.Errorf("html block must be script but %q", v)
+	}
+}
+
+func Test_ReadHTMLTag(t *testing.T) {
+	tag, n := ReadHTMLTag("<A Href='x.html' data-x=1 hidden/> b")
+	if n != 34 || tag.Name != "a" || !tag.SelfClosing || len(tag.Attrs) != 3 {
+		t.Errorf("tag must be a of 34 bytes but %v %d", tag, n)
+		return
+	}
+	if attr := tag.Attrs[0]; attr != (HTMLAttr{Name: "href", Value: "x.html", HasValue: true}) {
+		t.Errorf("attr must be href but %v", attr)
+	}
+	if attr := tag.Attrs[2]; attr != (HTMLAttr{Name: "hidden"}) {
+		t.Errorf("attr must be hidden but %v", attr)
+	}
+	if tag, n = ReadHTMLTag("</p >"); n != 5 || tag.Name != "p" || !tag.Closing {
+		t.Errorf("tag must be closing p but %v %d", tag, n)
+	}
+	for _, src := range []string{"<!-- a", "<a href=\"x>", "<a\"b>", "</p a>"} {
+		if _, n = ReadHTMLTag(src); n != 0 {
+			t.Errorf("%q must not be a tag but %d", src, n)
+		}
+	}
+}
+
+func Test_HTMLLineEnd(t *testing.T) {
+	// the tag in heading must end in the line
+	out, err := Parse("# title <span\nclass=x>\nparagraph\n")
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 2)
+	checkBlock(t, out.Children[0], TypeH1, 1)
+	checkTextBlock(t, out.Children[0].Children[0], "title <span")
+	checkBlock(t, out.Children[1], TypeP, 1)
+
+	// the tag and comment in paragraph must end before a blank line
+	for _, src := range []string{"a <b\n\nc> d\n", "x <!-- a\n\nb --> y\n"} {
+		out, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse error : %s", err)
+			return
+		}
+		checkBlock(t, out, TypeRoot, 2)
+		checkBlock(t, out.Children[0], TypeP, 1)
+		checkBlock(t, out.Children[1], TypeP, 1)
+	}
+}
+
+func Test_HeadingID(t *testing.T) {
+	src := "# Title {#top}  \n\n## *Sub* {#a.b}\n\n### no {#a b}\n\n- #### item {#item}\n"
+	out, err := Parse(src)
+	if err != nil {
+		t.Errorf("Parse error : %s", err)
+		return
+	}
+	checkBlock(t, out, TypeRoot, 4)
+	h1Block := out.Children[0]
+	if h1Block.Attributes["id"] != "top" {
+		t.Errorf("id must be top but %s", h1Block.Attributes["id"])
+	}
+	checkTextBlock(t, h1Block.Children[0], "Title")
+	checkPosition(t, h1Block.Children[0], Position{2, 1, 3}, Position{7, 1, 8})
+
+	h2Block := out.Children[1]
+	if h2Block.Attributes["id"] != "a.b" {
+		t.Errorf("id must be a.b but %s", h2Block.Attributes["id"])
+	}
+	checkEmphasisBlock(t, h2Block.Children[0], TypeEm, "Sub")
+
+	h3Block := out.Children[2]
+	if _, ok := h3Block.Attributes["id"]; ok {
+		t.Errorf("id must not be set but %s", h3Block.Attributes["id"])
+	}
+	checkTextBlock(t, h3Block.Children[0], "no {#a b}")
+
+	h4Block := out.Children[3].Children[0].Children[0]
+	if h4Block.Attributes["id"] != "item" {
+		t.Errorf("id must be item but %s", h4Block.Attributes["id"])
+	}
+}
+
 func printTypes(b *Block, indent string) string {
 	toTypeStr := func(t BlockType) string {
 		switch t {
@@ -563,10 +1692,20 @@ func printTypes(b *Block, indent string) string {
 			return "H1"
 		case TypeH2:
 			return "H2"
+		case TypeH3:
+			return "H3"
+		case TypeH4:
+			return "H4"
+		case TypeH5:
+			return "H5"
+		case TypeH6:
+			return "H6"
 		case TypeLI:
 			return "LI"
 		case TypeUL:
 			return "UL"
+		case TypeOL:
+			return "OL"
 		case TypeP:
 			return "P"
 		case TypePreCode:
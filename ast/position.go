@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"sort"
+)
+
+// offsetMap converts offsets in the collected content of container
+// blocks to offsets in the source. Each entry maps the beginning
+// of a copied line.
+type offsetMap []offsetPair
+
+type offsetPair struct {
+	inner int
+	outer int
+}
+
+func (m offsetMap) add(inner, outer int) offsetMap {
+	return append(m, offsetPair{inner: inner, outer: outer})
+}
+
+func (m offsetMap) toOuter(inner int) int {
+	i := sort.Search(len(m), func(i int) bool {
+		return m[i].inner > inner
+	}) - 1
+	if i < 0 {
+		return inner
+	}
+	return m[i].outer + inner - m[i].inner
+}
+
+// shiftPositions converts offsets of b and its descendants by m
+func shiftPositions(b *Block, m offsetMap) {
+	b.Start.Offset = m.toOuter(b.Start.Offset)
+	b.End.Offset = m.toOuter(b.End.Offset)
+	for i := range b.valueMap {
+		b.valueMap[i].outer = m.toOuter(b.valueMap[i].outer)
+	}
+	for _, c := range b.Children {
+		shiftPositions(c, m)
+	}
+}
+
+// fixPositions extends End of each block to cover its children
+// and sets Line and Column from offsets.
+func fixPositions(b *Block, lineStarts []int) {
+	for _, c := range b.Children {
+		fixPositions(c, lineStarts)
+		if c.End.Offset > b.End.Offset {
+			b.End.Offset = c.End.Offset
+		}
+	}
+	if b.End.Offset < b.Start.Offset {
+		b.End.Offset = b.Start.Offset
+	}
+	b.Start = toPosition(b.Start.Offset, lineStarts)
+	b.End = toPosition(b.End.Offset, lineStarts)
+}
+
+func toPosition(offset int, lineStarts []int) Position {
+	line := sort.Search(len(lineStarts), func(i int) bool {
+		return lineStarts[i] > offset
+	})
+	return Position{
+		Offset: offset,
+		Line:   line,
+		Column: offset - lineStarts[line-1] + 1,
+	}
+}
+
+// findLineStarts returns the offsets of the beginning of each line
+func findLineStarts(src string) []int {
+	lineStarts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return lineStarts
+}
@@ -0,0 +1,196 @@
+package ast
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ParseBytes and ParseReader split the source into chunks at the
+// blank lines between top-level blocks and parse them one by one.
+// Only a chunk is held as a string while parsing.
+
+// ParseBytes parses src markdown to block
+func ParseBytes(src []byte) (*Block, error) {
+	return defaultParser.ParseBytes(src)
+}
+
+// ParseReader parses markdown read from r to block
+func ParseReader(r io.Reader) (*Block, error) {
+	return defaultParser.ParseReader(r)
+}
+
+// ParseBytes parses src markdown to block. src is parsed at once
+// if p has block extensions because a custom block may contain
+// blank lines.
+func (p *Parser) ParseBytes(src []byte) (*Block, error) {
+	if len(p.blocks) > 0 {
+		return p.Parse(string(src))
+	}
+	c := newChunkParser(p)
+	for len(src) > 0 {
+		end := bytes.IndexByte(src, '\n') + 1
+		if end == 0 {
+			end = len(src)
+		}
+		if err := c.addLine(src[:end]); err != nil {
+			return nil, err
+		}
+		src = src[end:]
+	}
+	return c.finish()
+}
+
+// ParseReader parses markdown read from r to block. See ParseBytes
+// for the parser with block extensions.
+func (p *Parser) ParseReader(r io.Reader) (*Block, error) {
+	if len(p.blocks) > 0 {
+		src, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return p.Parse(string(src))
+	}
+	c := newChunkParser(p)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := c.addLine(line); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.finish()
+}
+
+type chunkParser struct {
+	parser *Parser
+	root   *Block
+	chunk  []byte
+	blank  bool
+	// the fence or the html block of kind 1-5 which is open at the
+	// end of the chunk. The chunk is not split until it is closed.
+	inFence  bool
+	htmlKind int
+
+	// position of the chunk
+	offset int
+	line   int
+}
+
+func newChunkParser(p *Parser) *chunkParser {
+	return &chunkParser{
+		parser: p,
+		root:   newBlockAt(TypeRoot, 0),
+		chunk:  make([]byte, 0, 4096),
+		line:   1,
+	}
+}
+
+func (c *chunkParser) addLine(line []byte) error {
+	if c.blank && !c.inFence && c.htmlKind == 0 && canSplitBefore(line) {
+		if err := c.flush(false); err != nil {
+			return err
+		}
+	}
+	c.chunk = append(c.chunk, line...)
+	if c.inFence && bytes.Contains(line, []byte("```")) {
+		c.inFence = false
+	}
+	if c.htmlKind > 0 && indexHTMLBlockEnd(string(line), c.htmlKind) >= 0 {
+		c.htmlKind = 0
+	}
+	// the parser ends paragraphs at an empty line only
+	c.blank = len(line) == 1
+	return nil
+}
+
+// flush parses the collected chunk and puts its blocks to the root.
+// The chunk is kept if its last block is open unless all is true.
+func (c *chunkParser) flush(all bool) error {
+	if len(c.chunk) == 0 {
+		return nil
+	}
+	chunk, _, err := parse(c.parser, string(c.chunk), false)
+	if err != nil {
+		if e, ok := err.(*ParseError); ok {
+			e.Offset += c.offset
+			e.Line += c.line - 1
+		}
+		return err
+	}
+	if !all && len(chunk.Children) > 0 {
+		c.inFence, c.htmlKind = openBlock(string(c.chunk), chunk.Children[len(chunk.Children)-1])
+		if c.inFence || c.htmlKind > 0 {
+			return nil
+		}
+	}
+	for _, b := range chunk.Children {
+		Walk(b, func(node *Block, entering bool) WalkStatus {
+			if entering {
+				moveLines(&node.Start, c.offset, c.line-1)
+				moveLines(&node.End, c.offset, c.line-1)
+			}
+			return WalkContinue
+		})
+		appendChild(c.root, b)
+	}
+	c.root.End = chunk.End
+	moveLines(&c.root.End, c.offset, c.line-1)
+	c.offset += len(c.chunk)
+	c.line += bytes.Count(c.chunk, []byte("\n"))
+	c.chunk = c.chunk[:0]
+	return nil
+}
+
+// openBlock reports whether b at the end of src is the fence or the
+// html block of kind 1-5 which is not closed. The rest of the source
+// may close them.
+func openBlock(src string, b *Block) (bool, int) {
+	switch b.Type {
+	case TypePreCode:
+		// the fence is closed by "```" after the info string
+		_, next := lineAt(src, b.Start.Offset)
+		return !strings.Contains(src[next:], "```"), 0
+	case TypeHTMLBlock:
+		if kind := htmlBlockKind(b.Value); kind > 0 && kind <= 5 && indexHTMLBlockEnd(b.Value, kind) < 0 {
+			return false, kind
+		}
+	}
+	return false, 0
+}
+
+func (c *chunkParser) finish() (*Block, error) {
+	if err := c.flush(true); err != nil {
+		return nil, err
+	}
+	c.root.Start = Position{Offset: 0, Line: 1, Column: 1}
+	if c.offset == 0 {
+		c.root.End = c.root.Start
+	}
+	return c.root, nil
+}
+
+func moveLines(p *Position, offset, lines int) {
+	p.Offset += offset
+	p.Line += lines
+}
+
+// canSplitBefore returns true if line after a blank line never
+// continues the previous block such as list and blockquote.
+func canSplitBefore(line []byte) bool {
+	switch line[0] {
+	case ' ', '\t', '\r', '\n', '>', '*', '-', '+':
+		return false
+	}
+	return !isDigit(line[0])
+}
@@ -0,0 +1,182 @@
+package ast
+
+import (
+	"strings"
+)
+
+// GitHub flavored markdown table. The first row of TypeTable is the
+// header row and each TypeTableCell has Attributes["align"]
+// ("left", "center" or "right") if its column is aligned.
+
+func stateReadTableLine(s *parseState, char byte) (stateFunc, error) {
+	if char == '\n' {
+		endTableRow(s)
+		s.index++
+		return stateReadTableNewLine, nil
+	}
+	s.rowValue = append(s.rowValue, char)
+	s.index++
+	return stateReadTableLine, nil
+}
+
+// stateReadTableNewLine decides whether the new line is the next row
+func stateReadTableNewLine(s *parseState, char byte) (stateFunc, error) {
+	if char == '\n' || interruptsParagraph(s.src, s.index) || char == '#' {
+		// table is ended. read this char as a new block
+		endTable(s)
+		return stateReadRootBlock, nil
+	}
+	s.rowStart = s.index
+	return stateReadTableLine, nil
+}
+
+func beginTable(s *parseState) {
+	tableBlock := newBlockAt(TypeTable, s.index)
+	appendChild(s.currentBlock, tableBlock)
+	s.blockStack.Push(s.currentBlock)
+	s.currentBlock = tableBlock
+	s.tableAligns = nil
+	s.rowValue = make([]byte, 0)
+	s.rowStart = s.index
+}
+
+// endTableRow puts the collected line to the table. The second
+// line of the table is the delimiter row.
+func endTableRow(s *parseState) {
+	line := string(s.rowValue)
+	s.rowValue = make([]byte, 0)
+	if len(s.currentBlock.Children) == 1 && s.tableAligns == nil {
+		s.tableAligns = parseTableAligns(line)
+		header := s.currentBlock.Children[0]
+		for i, cell := range header.Children {
+			setCellAlign(cell, s.tableAligns[i])
+		}
+		return
+	}
+	rowBlock := newBlockAt(TypeTableRow, s.rowStart)
+	rowBlock.End.Offset = s.rowStart + len(line)
+	appendChild(s.currentBlock, rowBlock)
+	cells, offsets := splitTableRow(line)
+	count := len(cells)
+	if s.tableAligns != nil {
+		// the number of cells is same as the header row
+		count = len(s.tableAligns)
+	}
+	for i := 0; i < count; i++ {
+		cellBlock := newBlockAt(TypeTableCell, rowBlock.End.Offset)
+		appendChild(rowBlock, cellBlock)
+		if i < len(cells) {
+			cellBlock.Start.Offset = s.rowStart + offsets[i]
+			cellBlock.End.Offset = cellBlock.Start.Offset + len(cells[i])
+			// a cell has no new line so parseInline never fails
+			_ = parseInline(s.parser, cells[i], cellBlock)
+			m := offsetMap{}.add(0, cellBlock.Start.Offset)
+			for _, c := range cellBlock.Children {
+				shiftPositions(c, m)
+			}
+		}
+		if s.tableAligns != nil {
+			setCellAlign(cellBlock, s.tableAligns[i])
+		}
+	}
+}
+
+func endTable(s *parseState) {
+	if len(s.rowValue) > 0 {
+		endTableRow(s)
+	}
+	s.blockStack.Clear()
+	s.currentBlock = s.root
+}
+
+func setCellAlign(cellBlock *Block, align string) {
+	if len(align) > 0 {
+		cellBlock.Attributes["align"] = align
+	}
+}
+
+// isTableAt returns true if src[i:] begins with the header row and
+// the delimiter row of the table.
+func isTableAt(src string, i int) bool {
+	header, next := lineAt(src, i)
+	if next >= len(src) || strings.IndexByte(header, '|') < 0 {
+		return false
+	}
+	delimiter, _ := lineAt(src, next)
+	aligns := parseTableAligns(delimiter)
+	cells, _ := splitTableRow(header)
+	return aligns != nil && len(aligns) == len(cells)
+}
+
+// lineAt returns the line at src[i:] and the index of the next line
+func lineAt(src string, i int) (string, int) {
+	end := strings.IndexByte(src[i:], '\n')
+	if end < 0 {
+		return src[i:], len(src)
+	}
+	return src[i : i+end], i + end + 1
+}
+
+// parseTableAligns returns the alignments of the columns.
+// nil is returned if line is not a delimiter row.
+func parseTableAligns(line string) []string {
+	cells, _ := splitTableRow(line)
+	if len(cells) == 0 || (len(cells) == 1 && strings.IndexByte(line, '|') < 0) {
+		return nil
+	}
+	aligns := make([]string, len(cells))
+	for i, cell := range cells {
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		dashes := strings.Trim(cell, ":")
+		if len(dashes) == 0 || strings.Trim(dashes, "-") != "" {
+			return nil
+		}
+		if left && right {
+			aligns[i] = "center"
+		} else if left {
+			aligns[i] = "left"
+		} else if right {
+			aligns[i] = "right"
+		}
+	}
+	return aligns
+}
+
+// splitTableRow splits line to trimmed cells and returns the offsets
+// of them in line. "\|" is a pipe in the cell.
+func splitTableRow(line string) ([]string, []int) {
+	begin := len(line) - len(strings.TrimLeft(line, " \t"))
+	end := len(strings.TrimRight(line, " \t\r"))
+	if begin < end && line[begin] == '|' {
+		begin++
+	}
+	if begin < end && line[end-1] == '|' && !strings.HasSuffix(line[begin:end], "\\|") {
+		end--
+	}
+	cells := make([]string, 0)
+	offsets := make([]int, 0)
+	cell := make([]byte, 0)
+	cellStart := begin
+	addCell := func() {
+		value := string(cell)
+		offsets = append(offsets, cellStart+len(value)-len(strings.TrimLeft(value, " \t")))
+		cells = append(cells, strings.TrimSpace(value))
+	}
+	for i := begin; i < end; i++ {
+		if line[i] == '\\' && i+1 < end && line[i+1] == '|' {
+			cell = append(cell, '|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			addCell()
+			cell = make([]byte, 0)
+			cellStart = i + 1
+			continue
+		}
+		cell = append(cell, line[i])
+	}
+	addCell()
+	return cells, offsets
+}
@@ -0,0 +1,25 @@
+package ast
+
+// Transformer rewrites the tree between parsing and rendering
+type Transformer interface {
+	Transform(root *Block) error
+}
+
+// TransformerFunc is a function which is used as Transformer
+type TransformerFunc func(root *Block) error
+
+// Transform calls f(root)
+func (f TransformerFunc) Transform(root *Block) error {
+	return f(root)
+}
+
+// Transform applies transformers to root in order. It stops at
+// the first error.
+func Transform(root *Block, transformers ...Transformer) error {
+	for _, t := range transformers {
+		if err := t.Transform(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
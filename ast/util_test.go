@@ -74,3 +74,24 @@ func checkBlock(t *testing.T, b *Block, tp BlockType, count int) {
 		return
 	}
 }
+
+func checkEmphasisBlock(t *testing.T, b *Block, tp BlockType, value string) {
+	if b.Type != tp {
+		t.Errorf("Type must be %d but %d\n%s", tp, b.Type, debug.Stack())
+		return
+	}
+	if len(b.Children) != 1 {
+		t.Errorf("Children must have 1 but %d\n%s", len(b.Children), debug.Stack())
+		return
+	}
+	checkTextBlock(t, b.Children[0], value)
+}
+
+func checkPosition(t *testing.T, b *Block, start, end Position) {
+	if b.Start != start {
+		t.Errorf("Start must be %v but %v\n%s", start, b.Start, debug.Stack())
+	}
+	if b.End != end {
+		t.Errorf("End must be %v but %v\n%s", end, b.End, debug.Stack())
+	}
+}
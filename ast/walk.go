@@ -0,0 +1,69 @@
+package ast
+
+// WalkStatus tells Walk how to go on
+type WalkStatus int
+
+const (
+	// WalkContinue visits the next node
+	WalkContinue WalkStatus = iota
+	// WalkSkipChildren skips the children of the entered node
+	WalkSkipChildren
+	// WalkStop stops walking
+	WalkStop
+)
+
+// WalkFunc is called when Walk enters and leaves node
+type WalkFunc func(node *Block, entering bool) WalkStatus
+
+// Visitor is called when Walk enters and leaves each node
+type Visitor interface {
+	Enter(node *Block) WalkStatus
+	Leave(node *Block) WalkStatus
+}
+
+// Walk visits root and its descendants in depth-first order.
+// fn is called for leaving node even if its children are skipped.
+// WalkStop is returned if fn stops walking.
+func Walk(root *Block, fn WalkFunc) WalkStatus {
+	status := fn(root, true)
+	if status == WalkStop {
+		return WalkStop
+	}
+	if status != WalkSkipChildren {
+		for _, c := range root.Children {
+			if Walk(c, fn) == WalkStop {
+				return WalkStop
+			}
+		}
+	}
+	if fn(root, false) == WalkStop {
+		return WalkStop
+	}
+	return WalkContinue
+}
+
+// WalkVisitor visits root and its descendants with v
+func WalkVisitor(root *Block, v Visitor) WalkStatus {
+	return Walk(root, func(node *Block, entering bool) WalkStatus {
+		if entering {
+			return v.Enter(node)
+		}
+		return v.Leave(node)
+	})
+}
+
+// TextOf returns the text of b without markups
+func TextOf(b *Block) string {
+	text := ""
+	Walk(b, func(node *Block, entering bool) WalkStatus {
+		if !entering {
+			return WalkContinue
+		}
+		switch node.Type {
+		case TypeText, TypeCode, TypeAnchor, TypeImage:
+			text += node.Value
+		}
+		return WalkContinue
+	})
+	return text
+}
@@ -0,0 +1,105 @@
+// Package highlight provides server-side syntax highlighting for code blocks.
+// Code is split to tokens by the Lexer registered for the language, and
+// each token is wrapped with <span class="hl-KIND">.
+package highlight
+
+import (
+	"strings"
+	"sync"
+)
+
+// Token kinds. These are used as a part of CSS class name.
+const (
+	Keyword  = "keyword"
+	String   = "string"
+	Comment  = "comment"
+	Number   = "number"
+	Literal  = "literal"
+	Key      = "key"
+	Section  = "section"
+	Variable = "variable"
+)
+
+// Token is a piece of code. Kind is empty for plain text
+type Token struct {
+	Kind  string
+	Value string
+}
+
+// Lexer splits code to tokens
+type Lexer interface {
+	Tokenize(code string) []Token
+}
+
+// LexerFunc is an adapter to use ordinary function as Lexer
+type LexerFunc func(code string) []Token
+
+// Tokenize calls f(code)
+func (f LexerFunc) Tokenize(code string) []Token {
+	return f(code)
+}
+
+var (
+	lexersMutex sync.RWMutex
+	lexers      = make(map[string]Lexer)
+)
+
+// Register makes lexer available for the language name and its aliases.
+// Names are case insensitive. Registering the same name again replaces
+// the previous lexer.
+func Register(lexer Lexer, name string, aliases ...string) {
+	lexersMutex.Lock()
+	defer lexersMutex.Unlock()
+	lexers[strings.ToLower(name)] = lexer
+	for _, alias := range aliases {
+		lexers[strings.ToLower(alias)] = lexer
+	}
+}
+
+// Lookup returns the lexer registered for name
+func Lookup(name string) (Lexer, bool) {
+	lexersMutex.RLock()
+	defer lexersMutex.RUnlock()
+	lexer, ok := lexers[strings.ToLower(name)]
+	return lexer, ok
+}
+
+// Highlight converts code to html. false is returned if no lexer
+// is registered for lang.
+func Highlight(lang, code string) (string, bool) {
+	lexer, ok := Lookup(lang)
+	if !ok {
+		return "", false
+	}
+	out := make([]byte, 0, len(code)*2)
+	for _, token := range lexer.Tokenize(code) {
+		if len(token.Kind) == 0 {
+			out = appendEscaped(out, token.Value)
+			continue
+		}
+		out = append(out, "<span class=\"hl-"...)
+		out = append(out, token.Kind...)
+		out = append(out, "\">"...)
+		out = appendEscaped(out, token.Value)
+		out = append(out, "</span>"...)
+	}
+	return string(out), true
+}
+
+func appendEscaped(out []byte, text string) []byte {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '<':
+			out = append(out, "&lt;"...)
+		case '>':
+			out = append(out, "&gt;"...)
+		case '&':
+			out = append(out, "&amp;"...)
+		case '"':
+			out = append(out, "&quot;"...)
+		default:
+			out = append(out, text[i])
+		}
+	}
+	return out
+}
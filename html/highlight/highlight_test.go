@@ -0,0 +1,64 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Go(t *testing.T) {
+	out, ok := Highlight("go", "// main\nfunc main() {\n\treturn \"<a>\"\n}")
+	if !ok {
+		t.Errorf("go must be registered")
+		return
+	}
+	expected := "<span class=\"hl-comment\">// main</span>\n" +
+		"<span class=\"hl-keyword\">func</span> main() {\n" +
+		"\t<span class=\"hl-keyword\">return</span> <span class=\"hl-string\">&quot;&lt;a&gt;&quot;</span>\n" +
+		"}"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_TOML(t *testing.T) {
+	out, ok := Highlight("TOML", "[DB]\nuser = 'fkm' # name\n")
+	if !ok {
+		t.Errorf("toml must be registered")
+		return
+	}
+	expected := "<span class=\"hl-section\">[DB]</span>\n" +
+		"<span class=\"hl-key\">user</span> = <span class=\"hl-string\">'fkm'</span> <span class=\"hl-comment\"># name</span>\n"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_YAML(t *testing.T) {
+	out, _ := Highlight("yml", "url: http://mokelab.com\nenabled: yes")
+	expected := "<span class=\"hl-key\">url</span>: http://mokelab.com\n" +
+		"<span class=\"hl-key\">enabled</span>: <span class=\"hl-literal\">yes</span>"
+	if out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Unknown(t *testing.T) {
+	_, ok := Highlight("brainfuck", "+++")
+	if ok {
+		t.Errorf("brainfuck must not be registered")
+	}
+}
+
+func Test_Register(t *testing.T) {
+	Register(LexerFunc(func(code string) []Token {
+		return []Token{{Kind: Keyword, Value: strings.ToUpper(code)}}
+	}), "upper", "up")
+	out, ok := Highlight("UP", "abc")
+	if !ok {
+		t.Errorf("up must be registered")
+		return
+	}
+	if out != "<span class=\"hl-keyword\">ABC</span>" {
+		t.Errorf("Wrong output %s", out)
+	}
+}
@@ -0,0 +1,229 @@
+package highlight
+
+import (
+	"strings"
+	"sync"
+)
+
+// Language is a simple Lexer driven by the lexical rules of a language.
+// It is enough for the most of C-like languages and config formats.
+type Language struct {
+	// Keywords are highlighted as Keyword
+	Keywords []string
+	// Literals such as true, false and null are highlighted as Literal
+	Literals []string
+	// LineComments are the beginnings of the comment until end of line
+	LineComments []string
+	// BlockComment is the pair of the beginning and the end of the comment
+	BlockComment [2]string
+	// Quotes are delimiters of string which supports backslash escape
+	Quotes []string
+	// RawQuotes are delimiters of string which does not support escape
+	RawQuotes []string
+	// KeySeparator makes a word or a string followed by it a Key
+	KeySeparator byte
+	// Sections enables [section] line of TOML
+	Sections bool
+	// Variables enables $VAR and ${VAR} of shell
+	Variables bool
+
+	once     sync.Once
+	keywords map[string]bool
+	literals map[string]bool
+}
+
+// Tokenize splits code to tokens
+func (l *Language) Tokenize(code string) []Token {
+	l.once.Do(func() {
+		l.keywords = toSet(l.Keywords)
+		l.literals = toSet(l.Literals)
+	})
+	tokens := make([]Token, 0)
+	plainStart := 0
+	i := 0
+	lineHead := true
+	for i < len(code) {
+		kind, end := l.scan(code, i, lineHead)
+		if kind == "" {
+			if code[i] == '\n' {
+				lineHead = true
+			} else if code[i] != ' ' && code[i] != '\t' {
+				lineHead = false
+			}
+			i = end
+			continue
+		}
+		if plainStart < i {
+			tokens = append(tokens, Token{Value: code[plainStart:i]})
+		}
+		tokens = append(tokens, Token{Kind: kind, Value: code[i:end]})
+		lineHead = false
+		i = end
+		plainStart = end
+	}
+	if plainStart < len(code) {
+		tokens = append(tokens, Token{Value: code[plainStart:]})
+	}
+	return tokens
+}
+
+// scan returns the kind and the end of the token at code[i:].
+// Empty kind is returned for plain text.
+func (l *Language) scan(code string, i int, lineHead bool) (string, int) {
+	for _, prefix := range l.LineComments {
+		if strings.HasPrefix(code[i:], prefix) && (prefix != "#" || i == 0 || isSpace(code[i-1])) {
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				return Comment, len(code)
+			}
+			return Comment, i + end
+		}
+	}
+	if begin := l.BlockComment[0]; len(begin) > 0 && strings.HasPrefix(code[i:], begin) {
+		end := strings.Index(code[i+len(begin):], l.BlockComment[1])
+		if end < 0 {
+			return Comment, len(code)
+		}
+		return Comment, i + len(begin) + end + len(l.BlockComment[1])
+	}
+	if quote, escape := l.quoteAt(code, i); len(quote) > 0 {
+		return l.keyOr(String, code, scanString(code, i, quote, escape), false)
+	}
+	char := code[i]
+	if l.Sections && lineHead && char == '[' {
+		end := strings.IndexByte(code[i:], ']')
+		if end >= 0 && strings.IndexByte(code[i:i+end], '\n') < 0 {
+			return Section, i + end + 1
+		}
+	}
+	if l.Variables && char == '$' && i+1 < len(code) {
+		if code[i+1] == '{' {
+			if end := strings.IndexByte(code[i:], '}'); end >= 0 {
+				return Variable, i + end + 1
+			}
+		}
+		end := i + 1
+		for end < len(code) && isWordChar(code[end]) {
+			end++
+		}
+		if end > i+1 {
+			return Variable, end
+		}
+	}
+	if i > 0 && isWordChar(code[i-1]) {
+		// middle of the word
+		return "", i + 1
+	}
+	if isDigit(char) || (char == '-' && i+1 < len(code) && isDigit(code[i+1])) {
+		end := i + 1
+		for end < len(code) && (isWordChar(code[end]) || code[end] == '.') {
+			end++
+		}
+		return Number, end
+	}
+	if isWordChar(char) {
+		end := i + 1
+		for end < len(code) && (isWordChar(code[end]) || l.isKeyChar(code[end])) {
+			end++
+		}
+		word := code[i:end]
+		if l.keywords[word] {
+			return Keyword, end
+		}
+		if l.literals[word] {
+			return Literal, end
+		}
+		kind, keyEnd := l.keyOr("", code, end, true)
+		if kind == Key {
+			return kind, keyEnd
+		}
+		// plain word
+		return "", end
+	}
+	return "", i + 1
+}
+
+// keyOr returns Key if the token ending at end is followed by KeySeparator.
+// Otherwise kind is returned.
+func (l *Language) keyOr(kind string, code string, end int, bare bool) (string, int) {
+	if l.KeySeparator == 0 {
+		return kind, end
+	}
+	j := end
+	for j < len(code) && (code[j] == ' ' || code[j] == '\t') {
+		j++
+	}
+	if j >= len(code) || code[j] != l.KeySeparator {
+		return kind, end
+	}
+	// bare word key like YAML needs a space after ':'
+	if bare && l.KeySeparator == ':' && j+1 < len(code) && !isSpace(code[j+1]) {
+		return kind, end
+	}
+	return Key, end
+}
+
+// quoteAt returns the longest quote at code[i:] and whether
+// it supports backslash escape.
+func (l *Language) quoteAt(code string, i int) (string, bool) {
+	quote := ""
+	escape := false
+	for _, q := range l.Quotes {
+		if len(q) > len(quote) && strings.HasPrefix(code[i:], q) {
+			quote = q
+			escape = true
+		}
+	}
+	for _, q := range l.RawQuotes {
+		if len(q) > len(quote) && strings.HasPrefix(code[i:], q) {
+			quote = q
+			escape = false
+		}
+	}
+	return quote, escape
+}
+
+func (l *Language) isKeyChar(char byte) bool {
+	return l.KeySeparator != 0 && (char == '-' || char == '.')
+}
+
+// scanString returns the end of the string beginning at code[i:]
+func scanString(code string, i int, quote string, escape bool) int {
+	j := i + len(quote)
+	for j < len(code) {
+		if escape && code[j] == '\\' {
+			j += 2
+			continue
+		}
+		if strings.HasPrefix(code[j:], quote) {
+			return j + len(quote)
+		}
+		if code[j] == '\n' && len(quote) == 1 && quote != "`" {
+			// not closed in this line
+			return j
+		}
+		j++
+	}
+	return len(code)
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func isWordChar(char byte) bool {
+	return char == '_' || isDigit(char) ||
+		('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+}
@@ -0,0 +1,98 @@
+package highlight
+
+// Built-in languages
+
+var goLanguage = &Language{
+	Keywords: []string{
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select", "struct",
+		"switch", "type", "var",
+	},
+	Literals:     []string{"true", "false", "nil", "iota"},
+	LineComments: []string{"//"},
+	BlockComment: [2]string{"/*", "*/"},
+	Quotes:       []string{"\"", "'"},
+	RawQuotes:    []string{"`"},
+}
+
+var javaLanguage = &Language{
+	Keywords: []string{
+		"abstract", "assert", "boolean", "break", "byte", "case", "catch",
+		"char", "class", "const", "continue", "default", "do", "double",
+		"else", "enum", "extends", "final", "finally", "float", "for", "goto",
+		"if", "implements", "import", "instanceof", "int", "interface",
+		"long", "native", "new", "package", "private", "protected", "public",
+		"record", "return", "short", "static", "strictfp", "super", "switch",
+		"synchronized", "this", "throw", "throws", "transient", "try", "var",
+		"void", "volatile", "while",
+	},
+	Literals:     []string{"true", "false", "null"},
+	LineComments: []string{"//"},
+	BlockComment: [2]string{"/*", "*/"},
+	Quotes:       []string{"\"\"\"", "\"", "'"},
+}
+
+var kotlinLanguage = &Language{
+	Keywords: []string{
+		"abstract", "annotation", "as", "break", "by", "catch", "class",
+		"companion", "const", "constructor", "continue", "crossinline",
+		"data", "do", "else", "enum", "external", "final", "finally", "for",
+		"fun", "get", "if", "import", "in", "infix", "init", "inline",
+		"inner", "interface", "internal", "is", "lateinit", "noinline",
+		"object", "open", "operator", "out", "override", "package",
+		"private", "protected", "public", "reified", "return", "sealed",
+		"set", "super", "suspend", "tailrec", "this", "throw", "try",
+		"typealias", "val", "var", "vararg", "when", "where", "while",
+	},
+	Literals:     []string{"true", "false", "null"},
+	LineComments: []string{"//"},
+	BlockComment: [2]string{"/*", "*/"},
+	Quotes:       []string{"\"", "'"},
+	RawQuotes:    []string{"\"\"\""},
+}
+
+var jsonLanguage = &Language{
+	Literals:     []string{"true", "false", "null"},
+	Quotes:       []string{"\""},
+	KeySeparator: ':',
+}
+
+var yamlLanguage = &Language{
+	Literals:     []string{"true", "false", "null", "yes", "no", "on", "off"},
+	LineComments: []string{"#"},
+	Quotes:       []string{"\""},
+	RawQuotes:    []string{"'"},
+	KeySeparator: ':',
+}
+
+var shellLanguage = &Language{
+	Keywords: []string{
+		"case", "do", "done", "elif", "else", "esac", "export", "fi", "for",
+		"function", "if", "in", "local", "return", "select", "then", "until",
+		"while",
+	},
+	LineComments: []string{"#"},
+	Quotes:       []string{"\""},
+	RawQuotes:    []string{"'"},
+	Variables:    true,
+}
+
+var tomlLanguage = &Language{
+	Literals:     []string{"true", "false"},
+	LineComments: []string{"#"},
+	Quotes:       []string{"\"\"\"", "\""},
+	RawQuotes:    []string{"'''", "'"},
+	KeySeparator: '=',
+	Sections:     true,
+}
+
+func init() {
+	Register(goLanguage, "go", "golang")
+	Register(javaLanguage, "java")
+	Register(kotlinLanguage, "kotlin", "kt", "kts")
+	Register(jsonLanguage, "json")
+	Register(yamlLanguage, "yaml", "yml")
+	Register(shellLanguage, "shell", "sh", "bash", "zsh")
+	Register(tomlLanguage, "toml")
+}
@@ -1,7 +1,18 @@
 package markdown
 
+import (
+	"io"
+)
+
 // Markdown provides API to convert markdown to other language
 type Markdown interface {
 	// Compile markdown to other language
 	Compile(src string) (string, error)
 }
+
+// Renderer is Markdown which can write the output block by block
+type Renderer interface {
+	Markdown
+	// Render writes markdown compiled to other language to w
+	Render(w io.Writer, src []byte) error
+}
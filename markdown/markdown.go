@@ -0,0 +1,410 @@
+package markdown
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	md "github.com/mokelab-go/markdown"
+	"github.com/mokelab-go/markdown/ast"
+	"github.com/mokelab-go/markdown/render"
+)
+
+// Markdown is printed with "-" bullets, "*" emphasis and "```" fences.
+// Blocks are separated by a blank line. Adjacent lists are printed
+// with "*" bullets or ")" delimiters alternately not to be merged.
+// Delimiter chars in text are escaped with a backslash.
+
+type impl struct {
+	r *render.Renderer
+}
+
+// Option configures the compiler
+type Option = render.Option
+
+// Options are the ones of render package. Blocks of custom types
+// are printed as their Value or children.
+var (
+	WithLenient      = render.WithLenient
+	WithTransformers = render.WithTransformers
+	WithParser       = render.WithParser
+)
+
+// NewMarkdown returns the compiler which normalizes markdown
+func NewMarkdown(opts ...Option) md.Renderer {
+	return &impl{r: render.New(nil, opts...)}
+}
+
+func (o *impl) Compile(src string) (string, error) {
+	tree, err := o.r.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return Format(tree), nil
+}
+
+// Render writes the output to w block by block
+func (o *impl) Render(w io.Writer, src []byte) error {
+	tree, err := o.r.Parse(string(src))
+	if err != nil {
+		return err
+	}
+	out := make([]byte, 0, 1024)
+	for i, e := range tree.Children {
+		out = out[:0]
+		if i > 0 {
+			out = appendStr(out, "\n")
+		}
+		if isBlock(e.Type) {
+			out = printSibling(out, tree.Children, i)
+		} else {
+			out = printBlocks(out, []*ast.Block{e}, true)
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format prints the tree as markdown
+func Format(tree *ast.Block) string {
+	return string(printBlocks(make([]byte, 0), tree.Children, true))
+}
+
+func appendStr(out []byte, text string) []byte {
+	return append(out, text...)
+}
+
+// printBlocks prints blocks line by line. Blocks are separated
+// by a blank line if loose is true. Adjacent inline blocks are
+// printed as a line.
+func printBlocks(out []byte, blocks []*ast.Block, loose bool) []byte {
+	for i := 0; i < len(blocks); i++ {
+		if i > 0 && loose {
+			out = appendStr(out, "\n")
+		}
+		if !isBlock(blocks[i].Type) {
+			j := i
+			for j < len(blocks) && !isBlock(blocks[j].Type) {
+				out = printInline(out, blocks[j])
+				j++
+			}
+			out = appendStr(out, "\n")
+			i = j - 1
+			continue
+		}
+		out = printSibling(out, blocks, i)
+	}
+	return out
+}
+
+// printSibling prints blocks[i]. The previous blocks decide the list marker.
+func printSibling(out []byte, blocks []*ast.Block, i int) []byte {
+	if isList(blocks[i].Type) {
+		return printList(out, blocks[i], altMarker(blocks, i))
+	}
+	return printBlock(out, blocks[i])
+}
+
+// altMarker returns true if the list blocks[i] is printed with the
+// alternative marker. Adjacent lists of the same type must have
+// different markers to be parsed as different lists.
+func altMarker(blocks []*ast.Block, i int) bool {
+	n := 0
+	for ; i > 0 && blocks[i-1].Type == blocks[i].Type; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func printBlock(out []byte, block *ast.Block) []byte {
+	switch block.Type {
+	case ast.TypeRoot:
+		return printBlocks(out, block.Children, true)
+	case ast.TypeH1, ast.TypeH2, ast.TypeH3, ast.TypeH4, ast.TypeH5, ast.TypeH6:
+		out = appendStr(out, strings.Repeat("#", ast.HeadingLevel(block.Type)))
+		out = appendStr(out, " ")
+		out = printInlines(out, block)
+		if id := block.Attributes["id"]; len(id) > 0 {
+			out = appendStr(out, " {#"+id+"}")
+		}
+		out = appendStr(out, "\n")
+	case ast.TypeP:
+		out = printInlines(out, block)
+		out = appendStr(out, "\n")
+	case ast.TypePreCode:
+		out = appendStr(out, "```"+block.Attributes["info"]+"\n")
+		for _, e := range block.Children {
+			out = appendStr(out, e.Value)
+		}
+		out = appendStr(out, "```\n")
+	case ast.TypeBlockquote:
+		content := printBlocks(make([]byte, 0), block.Children, true)
+		if len(content) == 0 {
+			// empty quote
+			out = appendStr(out, ">\n")
+		} else {
+			out = appendIndented(out, content, "> ", "> ")
+		}
+	case ast.TypeUL, ast.TypeOL:
+		out = printList(out, block, false)
+	case ast.TypeTable:
+		out = printTable(out, block)
+	case ast.TypeHTMLBlock:
+		out = appendStr(out, block.Value+"\n")
+	default:
+		// custom block
+		if len(block.Value) > 0 {
+			out = appendStr(out, block.Value+"\n")
+		} else {
+			out = printBlocks(out, block.Children, true)
+		}
+	}
+	return out
+}
+
+func printList(out []byte, block *ast.Block, alt bool) []byte {
+	loose := false
+	for _, item := range block.Children {
+		for _, c := range item.Children {
+			if c.Type == ast.TypeP {
+				loose = true
+			}
+		}
+	}
+	start := 1
+	if block.Type == ast.TypeOL {
+		if n, err := strconv.Atoi(block.Attributes["start"]); err == nil {
+			start = n
+		}
+	}
+	for i, item := range block.Children {
+		if i > 0 && loose {
+			out = appendStr(out, "\n")
+		}
+		marker := "- "
+		if alt {
+			marker = "* "
+		}
+		if block.Type == ast.TypeOL {
+			if alt {
+				marker = strconv.Itoa(start+i) + ") "
+			} else {
+				marker = strconv.Itoa(start+i) + ". "
+			}
+		}
+		content := make([]byte, 0)
+		if checked, ok := item.Attributes["checked"]; ok {
+			if checked == "true" {
+				content = appendStr(content, "[x] ")
+			} else {
+				content = appendStr(content, "[ ] ")
+			}
+		}
+		content = printBlocks(content, item.Children, loose)
+		if len(item.Children) == 0 {
+			content = appendStr(content, "\n")
+		}
+		out = appendIndented(out, content, marker, strings.Repeat(" ", len(marker)))
+	}
+	return out
+}
+
+func printTable(out []byte, block *ast.Block) []byte {
+	for i, row := range block.Children {
+		out = printTableRow(out, row)
+		if i > 0 {
+			continue
+		}
+		// delimiter row
+		out = appendStr(out, "|")
+		for _, cell := range row.Children {
+			switch cell.Attributes["align"] {
+			case "left":
+				out = appendStr(out, " :--- |")
+			case "center":
+				out = appendStr(out, " :---: |")
+			case "right":
+				out = appendStr(out, " ---: |")
+			default:
+				out = appendStr(out, " --- |")
+			}
+		}
+		out = appendStr(out, "\n")
+	}
+	return out
+}
+
+func printTableRow(out []byte, row *ast.Block) []byte {
+	out = appendStr(out, "|")
+	for _, cell := range row.Children {
+		value := printInlines(make([]byte, 0), cell)
+		out = appendStr(out, " ")
+		out = appendStr(out, strings.ReplaceAll(string(value), "|", "\\|"))
+		out = appendStr(out, " |")
+	}
+	return appendStr(out, "\n")
+}
+
+func printInlines(out []byte, block *ast.Block) []byte {
+	for _, e := range block.Children {
+		out = printInline(out, e)
+	}
+	return out
+}
+
+func printInline(out []byte, block *ast.Block) []byte {
+	switch block.Type {
+	case ast.TypeText:
+		if len(block.Value) == 0 {
+			out = printInlines(out, block)
+		} else {
+			out = appendStr(out, escapeText(block.Value))
+		}
+	case ast.TypeEm:
+		out = printEmphasis(out, block, "*", "_")
+	case ast.TypeStrong:
+		out = printEmphasis(out, block, "**", "__")
+	case ast.TypeDel:
+		delim := "~~"
+		if block.Attributes["delim"] == "~" {
+			delim = "~"
+		}
+		out = appendStr(out, delim)
+		out = printInlines(out, block)
+		out = appendStr(out, delim)
+	case ast.TypeCode:
+		out = printCode(out, block.Value)
+	case ast.TypeHTML:
+		out = appendStr(out, block.Value)
+	case ast.TypeAnchor:
+		out = appendStr(out, "["+block.Value+"]("+block.URL+")")
+	case ast.TypeImage:
+		out = appendStr(out, "!["+block.Value+"]("+block.URL)
+		names := make([]string, 0, len(block.Attributes))
+		for name := range block.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out = appendStr(out, " "+name+"="+block.Attributes[name])
+		}
+		out = appendStr(out, ")")
+	default:
+		// custom inline
+		if len(block.Value) > 0 {
+			out = appendStr(out, block.Value)
+		} else {
+			out = printInlines(out, block)
+		}
+	}
+	return out
+}
+
+// printEmphasis prints the children between delim. alt is used if
+// the children begin and end with emphasis which would be merged
+// with delim such as "***a***".
+func printEmphasis(out []byte, block *ast.Block, delim, alt string) []byte {
+	if n := len(block.Children); n > 0 && isEmphasis(block.Children[0].Type) && isEmphasis(block.Children[n-1].Type) {
+		delim = alt
+	}
+	out = appendStr(out, delim)
+	out = printInlines(out, block)
+	return appendStr(out, delim)
+}
+
+// printCode prints value between the backticks longer than any
+// backtick run in value. Empty code can not be printed.
+func printCode(out []byte, value string) []byte {
+	if len(value) == 0 {
+		return out
+	}
+	longest, n := 0, 0
+	for i := 0; i < len(value); i++ {
+		if value[i] != '`' {
+			n = 0
+			continue
+		}
+		n++
+		if n > longest {
+			longest = n
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	// the parser removes one space on both sides
+	first, last := value[0], value[len(value)-1]
+	if first == '`' || last == '`' || (first == ' ' && last == ' ' && strings.Trim(value, " ") != "") {
+		value = " " + value + " "
+	}
+	return appendStr(out, fence+value+fence)
+}
+
+// escapeText escapes the chars which may be read as delimiters.
+// '_' between letters is never a delimiter.
+func escapeText(text string) string {
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		char := text[i]
+		switch {
+		case char == '\\' && (i+1 == len(text) || isEscapable(text[i+1])):
+		case char == '*' || char == '~':
+		case char == '_' && !(isWordBefore(text, i) && isWordAfter(text, i+1)):
+		default:
+			out = append(out, char)
+			continue
+		}
+		out = append(out, '\\', char)
+	}
+	return string(out)
+}
+
+func isEscapable(char byte) bool {
+	return char == '\\' || char == '*' || char == '_' || char == '~'
+}
+
+func isWordBefore(text string, i int) bool {
+	r, n := utf8.DecodeLastRuneInString(text[:i])
+	return n > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isWordAfter(text string, i int) bool {
+	r, n := utf8.DecodeRuneInString(text[i:])
+	return n > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// appendIndented appends lines of content. The first line begins with
+// first and the others begin with rest. Blank lines are not indented
+// except the first line such as an empty list item.
+func appendIndented(out []byte, content []byte, first, rest string) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "\n" && i > 0 {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		out = appendStr(out, prefix+line)
+	}
+	return out
+}
+
+func isBlock(t ast.BlockType) bool {
+	return !ast.IsInline(t)
+}
+
+func isEmphasis(t ast.BlockType) bool {
+	return t == ast.TypeEm || t == ast.TypeStrong
+}
+
+func isList(t ast.BlockType) bool {
+	return t == ast.TypeUL || t == ast.TypeOL
+}
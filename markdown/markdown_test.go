@@ -0,0 +1,283 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mokelab-go/markdown/ast"
+)
+
+const src1 = "# Hello *world*\n\n\n" +
+	"Some __strong__ and `code` [link](./a.html) ![img](./a.png width=100 height=200)\n\n" +
+	" * a\n" +
+	" * b\n" +
+	"   * nested\n\n" +
+	"3) x\n" +
+	"4) y\n\n" +
+	"   more\n\n" +
+	"> quote\n" +
+	">\n" +
+	"> - q\n\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n\n" +
+	"| a | b |\n" +
+	"|:-|-:|\n" +
+	"| 1 | x \\| y |\n\n" +
+	" - [x] done\n" +
+	" - [ ] todo\n"
+
+const expected1 = "# Hello *world*\n\n" +
+	"Some **strong** and `code` [link](./a.html) ![img](./a.png height=200 width=100)\n\n" +
+	"- a\n" +
+	"- b\n" +
+	"  - nested\n\n" +
+	"3. x\n\n" +
+	"4. y\n\n" +
+	"   more\n\n" +
+	"> quote\n" +
+	">\n" +
+	"> - q\n\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n\n" +
+	"| a | b |\n" +
+	"| :--- | ---: |\n" +
+	"| 1 | x \\| y |\n\n" +
+	"- [x] done\n" +
+	"- [ ] todo\n"
+
+func Test_Compile(t *testing.T) {
+	m := NewMarkdown()
+	out, err := m.Compile(src1)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	if out != expected1 {
+		t.Errorf("Output must be %s but %s", expected1, out)
+	}
+	// normalized markdown is not changed
+	out, err = m.Compile(expected1)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	if out != expected1 {
+		t.Errorf("Output must be %s but %s", expected1, out)
+	}
+}
+
+func Test_Format(t *testing.T) {
+	tree, err := ast.Parse("# Title\n\n[link](./a.html)\n")
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	ast.Walk(tree, func(node *ast.Block, entering bool) ast.WalkStatus {
+		if entering && node.Type == ast.TypeAnchor {
+			node.URL = "./b.html"
+		}
+		return ast.WalkContinue
+	})
+	expected := "# Title\n\n[link](./b.html)\n"
+	if out := Format(tree); out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+
+	// raw html and heading ids are printed as they are
+	expected = "<div>\n*x*\n</div>\n\na<br>b <!-- c -->\n\n## Title {#top}\n"
+	tree, err = ast.Parse(expected)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	if out := Format(tree); out != expected {
+		t.Errorf("Output must be %s but %s", expected, out)
+	}
+}
+
+func Test_Render(t *testing.T) {
+	m := NewMarkdown()
+	srcs := []string{"# Title\n\n* a\n* b\n\ntext\n", "a\n  \nb\n", "```\n\nx\n\n``` y\n\n[z\n\n# w\n"}
+	var w strings.Builder
+	for _, src := range srcs {
+		expected, err := m.Compile(src)
+		if err != nil {
+			t.Errorf("error : %s", err)
+			return
+		}
+		w.Reset()
+		if err := m.Render(&w, []byte(src)); err != nil {
+			t.Errorf("error : %s", err)
+			return
+		}
+		if w.String() != expected {
+			t.Errorf("Output must be %s but %s", expected, w.String())
+		}
+	}
+	if err := m.Render(&w, []byte("####### seven")); err == nil {
+		t.Errorf("Render must return the parse error")
+	}
+}
+
+func Test_Idempotent(t *testing.T) {
+	srcs := []string{
+		src1,
+		"* a\n- b\n",
+		"1. a\n2) b\n",
+		"- a\n\n* b\n\n- c\n",
+		"_2*3_\n",
+		"__a*b__ and **c_d**\n",
+		"``a`b`` and `` `c` `` and `` ` ``\n",
+		"``foo`` bar\n",
+		"a `  ` b\n",
+		"2 * 3 and a*b and snake_case and ~/path\n",
+		"C:\\path \\* \\_ \\\\ a\\\n",
+		"*a **b** c* and ***d*** and **_e_** and *_f_*\n",
+		"~~a ~b~ c~~\n",
+		"**bold with * star** and *a*\\*\n",
+		"1. \n2. b\n",
+		">\n\ntext\n",
+		"- >\n",
+		"```\na```\n",
+	}
+	m := NewMarkdown()
+	for _, src := range srcs {
+		out, err := m.Compile(src)
+		if err != nil {
+			t.Errorf("error : %s", err)
+			continue
+		}
+		// the structure is not changed
+		if expected, actual := treeOf(t, src), treeOf(t, out); expected != actual {
+			t.Errorf("Tree of %q must be %s but %s", out, expected, actual)
+		}
+		again, err := m.Compile(out)
+		if err != nil {
+			t.Errorf("error : %s", err)
+			continue
+		}
+		if again != out {
+			t.Errorf("Output must be %q but %q", out, again)
+		}
+		var w strings.Builder
+		if err := m.Render(&w, []byte(src)); err != nil {
+			t.Errorf("error : %s", err)
+			continue
+		}
+		if w.String() != out {
+			t.Errorf("Render output must be %q but %q", out, w.String())
+		}
+	}
+
+	// empty code is not printed
+	tree, _ := ast.Parse("a `b` c\n")
+	tree.Children[0].Children[1].Value = ""
+	if out := Format(tree); out != "a  c\n" {
+		t.Errorf("Output must be %q but %q", "a  c\n", out)
+	}
+}
+
+func Test_Escape(t *testing.T) {
+	tests := []struct{ src, expected string }{
+		{"1. \n2. b", "1. \n2. b\n"},
+		{">\n\ntext", ">\n\ntext\n"},
+		{"```\na```", "```\na```\n"},
+		{"a*b_c ~d~ ~~e~~ *f*", "a\\*b_c ~d~ ~~e~~ *f*\n"},
+	}
+	m := NewMarkdown()
+	for _, test := range tests {
+		out, err := m.Compile(test.src)
+		if err != nil {
+			t.Errorf("error : %s", err)
+			continue
+		}
+		if out != test.expected {
+			t.Errorf("Output must be %q but %q", test.expected, out)
+		}
+	}
+}
+
+// treeOf returns the types and values of the tree of src
+func treeOf(t *testing.T, src string) string {
+	tree, err := ast.Parse(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return ""
+	}
+	text := ""
+	ast.Walk(tree, func(node *ast.Block, entering bool) ast.WalkStatus {
+		if entering {
+			text += fmt.Sprintf("(%s %q", node.Type, node.Value)
+		} else {
+			text += ")"
+		}
+		return ast.WalkContinue
+	})
+	return text
+}
+
+var (
+	typeTOC     = ast.NewBlockType("md_toc", false)
+	typeMention = ast.NewBlockType("md_mention", true)
+)
+
+// tocParser parses "[TOC]" line
+type tocParser struct{}
+
+func (tocParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (tocParser) ParseBlock(src string) (*ast.Block, int) {
+	if !strings.HasPrefix(src, "[TOC]") {
+		return nil, 0
+	}
+	return &ast.Block{Type: typeTOC, Value: "[TOC]"}, len("[TOC]")
+}
+
+// mentionParser parses "@name"
+type mentionParser struct{}
+
+func (mentionParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+func (mentionParser) ParseInline(src string) (*ast.Block, int) {
+	end := 1
+	for end < len(src) && 'a' <= src[end] && src[end] <= 'z' {
+		end++
+	}
+	if end == 1 {
+		return nil, 0
+	}
+	return &ast.Block{Type: typeMention, Value: src[:end]}, end
+}
+
+func Test_Extension(t *testing.T) {
+	p := ast.NewParser(ast.Extension{
+		Blocks:  []ast.BlockParser{tocParser{}},
+		Inlines: []ast.InlineParser{mentionParser{}},
+	})
+	m := NewMarkdown(WithParser(p))
+	src := "[TOC]\n\nhello   @alice *and* @bob\n"
+	expected := "[TOC]\n\nhello   @alice *and* @bob\n"
+	out, err := m.Compile(src)
+	if err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	if out != expected {
+		t.Errorf("Output must be %q but %q", expected, out)
+	}
+	var w strings.Builder
+	if err := m.Render(&w, []byte(src)); err != nil {
+		t.Errorf("error : %s", err)
+		return
+	}
+	if w.String() != expected {
+		t.Errorf("Output must be %q but %q", expected, w.String())
+	}
+}
@@ -0,0 +1,249 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mokelab-go/markdown/ast"
+	"github.com/mokelab-go/markdown/html/highlight"
+)
+
+// HTMLFuncs returns the functions which print blocks as html.
+// The returned map can be modified to build other formats.
+func HTMLFuncs() Funcs {
+	return Funcs{
+		ast.TypeRoot:       printChildren,
+		ast.TypeH1:         printHeading,
+		ast.TypeH2:         printHeading,
+		ast.TypeH3:         printHeading,
+		ast.TypeH4:         printHeading,
+		ast.TypeH5:         printHeading,
+		ast.TypeH6:         printHeading,
+		ast.TypeP:          printTag("<p>", "</p>\n\n"),
+		ast.TypePreCode:    printPreCode,
+		ast.TypeBlockquote: printTag("<blockquote>\n", "</blockquote>\n\n"),
+		ast.TypeTable:      printTable,
+		ast.TypeTableRow:   printTag("<tr>\n", "</tr>\n"),
+		ast.TypeTableCell:  printTableCell,
+		ast.TypeUL:         printTag("<ul>\n", "</ul>\n\n"),
+		ast.TypeOL:         printOL,
+		ast.TypeLI:         printLI,
+		ast.TypeAnchor:     printAnchor,
+		ast.TypeImage:      printImage,
+		ast.TypeText:       printText,
+		ast.TypeEm:         printTag("<em>", "</em>"),
+		ast.TypeStrong:     printTag("<strong>", "</strong>"),
+		ast.TypeDel:        printTag("<del>", "</del>"),
+		ast.TypeCode:       printCode,
+		ast.TypeHTMLBlock:  printHTMLBlock,
+		ast.TypeHTML:       printHTML,
+	}
+}
+
+func printChildren(c *Context, out []byte, block *ast.Block) []byte {
+	return c.Children(out, block)
+}
+
+// printTag returns the function which prints children between open and close
+func printTag(open, close string) Func {
+	return func(c *Context, out []byte, block *ast.Block) []byte {
+		out = AppendStr(out, open)
+		out = c.Children(out, block)
+		return AppendStr(out, close)
+	}
+}
+
+func printHeading(c *Context, out []byte, block *ast.Block) []byte {
+	level := ast.HeadingLevel(block.Type)
+	id := c.HeadingID(block)
+	if len(id) > 0 {
+		out = AppendStr(out, fmt.Sprintf("<h%d id=\"%s\">", level, Escape(id)))
+	} else {
+		out = AppendStr(out, fmt.Sprintf("<h%d>", level))
+	}
+	out = c.Children(out, block)
+	if c.r.permalink && len(id) > 0 {
+		out = AppendStr(out, fmt.Sprintf(" <a class=\"permalink\" href=\"#%s\" aria-label=\"Permalink\">#</a>", Escape(id)))
+	}
+	return AppendStr(out, fmt.Sprintf("</h%d>\n\n", level))
+}
+
+// HeadingID returns the id of the heading. The explicit id is returned
+// if the heading has it. Otherwise the id made from the text is returned
+// if heading ids are enabled. The ids are unique in the document.
+func (c *Context) HeadingID(block *ast.Block) string {
+	if id, ok := c.headingIDs[block]; ok {
+		return id
+	}
+	if !c.r.headingID {
+		return ""
+	}
+	slug := slugify(ast.TextOf(block))
+	if len(slug) == 0 {
+		slug = "section"
+	}
+	id := slug
+	for n := 1; c.usedIDs[id]; n++ {
+		id = fmt.Sprintf("%s-%d", slug, n)
+	}
+	c.headingIDs[block] = id
+	c.usedIDs[id] = true
+	return id
+}
+
+func printPreCode(c *Context, out []byte, block *ast.Block) []byte {
+	lang := block.Attributes["lang"]
+	if len(lang) == 0 {
+		out = AppendStr(out, "<pre><code>")
+	} else {
+		out = AppendStr(out, fmt.Sprintf("<pre><code class=\"language-%s\">", Escape(lang)))
+	}
+	if code, ok := c.highlightCode(lang, block); ok {
+		out = AppendStr(out, code)
+	} else {
+		out = c.Children(out, block)
+	}
+	return AppendStr(out, "</code></pre>\n\n")
+}
+
+// highlightCode returns highlighted html of pre code block
+func (c *Context) highlightCode(lang string, block *ast.Block) (string, bool) {
+	if !c.r.highlight || len(lang) == 0 {
+		return "", false
+	}
+	code := ""
+	for _, e := range block.Children {
+		code += e.Value
+	}
+	return highlight.Highlight(lang, code)
+}
+
+func printTable(c *Context, out []byte, block *ast.Block) []byte {
+	out = AppendStr(out, "<table>\n")
+	for i, row := range block.Children {
+		if i == 0 {
+			out = AppendStr(out, "<thead>\n")
+			c.Header = true
+			out = c.Block(out, row)
+			c.Header = false
+			out = AppendStr(out, "</thead>\n")
+			if len(block.Children) > 1 {
+				out = AppendStr(out, "<tbody>\n")
+			}
+			continue
+		}
+		out = c.Block(out, row)
+	}
+	if len(block.Children) > 1 {
+		out = AppendStr(out, "</tbody>\n")
+	}
+	return AppendStr(out, "</table>\n\n")
+}
+
+func printTableCell(c *Context, out []byte, block *ast.Block) []byte {
+	tag := CellTag(c)
+	if align := block.Attributes["align"]; len(align) > 0 {
+		out = AppendStr(out, fmt.Sprintf("<%s style=\"text-align: %s\">", tag, align))
+	} else {
+		out = AppendStr(out, "<"+tag+">")
+	}
+	out = c.Children(out, block)
+	return AppendStr(out, "</"+tag+">\n")
+}
+
+// CellTag returns "th" in the header row and "td" in other rows
+func CellTag(c *Context) string {
+	if c.Header {
+		return "th"
+	}
+	return "td"
+}
+
+func printOL(c *Context, out []byte, block *ast.Block) []byte {
+	start := block.Attributes["start"]
+	if len(start) == 0 || start == "1" {
+		out = AppendStr(out, "<ol>\n")
+	} else {
+		out = AppendStr(out, fmt.Sprintf("<ol start=\"%s\">\n", start))
+	}
+	out = c.Children(out, block)
+	return AppendStr(out, "</ol>\n\n")
+}
+
+func printLI(c *Context, out []byte, block *ast.Block) []byte {
+	checked, task := block.Attributes["checked"]
+	if !task {
+		out = AppendStr(out, " <li>")
+	} else if checked == "true" {
+		out = AppendStr(out, " <li class=\"task-list-item\"><input type=\"checkbox\" "+c.boolAttr("checked")+" "+c.boolAttr("disabled")+c.voidEnd()+" ")
+	} else {
+		out = AppendStr(out, " <li class=\"task-list-item\"><input type=\"checkbox\" "+c.boolAttr("disabled")+c.voidEnd()+" ")
+	}
+	out = c.Children(out, block)
+	return AppendStr(out, " </li>\n")
+}
+
+func printAnchor(c *Context, out []byte, block *ast.Block) []byte {
+	return AppendStr(out, fmt.Sprintf("<a href=\"%s\">%s</a>", Escape(c.URL(block.URL)), Escape(block.Value)))
+}
+
+func printImage(c *Context, out []byte, block *ast.Block) []byte {
+	return AppendStr(out, fmt.Sprintf("<img src=\"%s\" title=\"%s\"%s", Escape(c.URL(block.URL)), Escape(block.Value), c.voidEnd()))
+}
+
+func printText(c *Context, out []byte, block *ast.Block) []byte {
+	if len(block.Value) == 0 {
+		return c.Children(out, block)
+	}
+	return AppendStr(out, Escape(block.Value))
+}
+
+func printCode(c *Context, out []byte, block *ast.Block) []byte {
+	out = AppendStr(out, "<code>")
+	out = AppendStr(out, Escape(block.Value))
+	return AppendStr(out, "</code>\n\n")
+}
+
+// voidEnd returns the end of void element such as img
+func (c *Context) voidEnd() string {
+	if c.r.xhtml {
+		return " />"
+	}
+	return "/>"
+}
+
+func (c *Context) boolAttr(name string) string {
+	if c.r.xhtml {
+		return name + "=\"" + name + "\""
+	}
+	return name
+}
+
+// slugify makes id of the heading from its text. Letters and digits
+// are kept and spaces are replaced with '-'.
+func slugify(text string) string {
+	slug := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		if unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			slug = append(slug, r)
+		} else if unicode.IsSpace(r) {
+			slug = append(slug, '-')
+		}
+	}
+	return string(slug)
+}
+
+func printHTMLBlock(c *Context, out []byte, block *ast.Block) []byte {
+	n := len(out)
+	out = c.HTML(out, block.Value, nil)
+	if len(out) == n {
+		// everything is removed
+		return out
+	}
+	return AppendStr(out, "\n")
+}
+
+func printHTML(c *Context, out []byte, block *ast.Block) []byte {
+	return c.HTML(out, block.Value, nil)
+}
@@ -0,0 +1,309 @@
+package render
+
+import (
+	"io"
+	"strings"
+
+	"github.com/mokelab-go/markdown/ast"
+)
+
+// Func prints block to out and returns it
+type Func func(c *Context, out []byte, block *ast.Block) []byte
+
+// Funcs maps block types to the functions which print them
+type Funcs map[ast.BlockType]Func
+
+// Renderer compiles markdown with Funcs. Hooks registered by
+// WithHook are used instead of Funcs.
+type Renderer struct {
+	funcs  Funcs
+	hooks  Funcs
+	parser *ast.Parser
+
+	highlight bool
+	lenient   bool
+	warn      func(*ast.ParseError)
+	unsafe    bool
+	rawHTML   bool
+	schemes   map[string]bool
+	policy    Policy
+	headingID bool
+	permalink bool
+	xhtml     bool
+
+	transformers []ast.Transformer
+}
+
+// Option configures the Renderer
+type Option func(*Renderer)
+
+// WithHighlight enables server-side syntax highlighting of pre code blocks.
+// Code is highlighted if its language is registered in highlight package.
+func WithHighlight() Option {
+	return func(r *Renderer) {
+		r.highlight = true
+	}
+}
+
+// WithLenient makes Compile never fail on broken markdown. Broken
+// constructs are printed as text and passed to warn if it is not nil.
+func WithLenient(warn func(*ast.ParseError)) Option {
+	return func(r *Renderer) {
+		r.lenient = true
+		r.warn = warn
+	}
+}
+
+// WithTransformers adds transformers which rewrite the tree before
+// rendering. They are applied in the order of addition.
+func WithTransformers(transformers ...ast.Transformer) Option {
+	return func(r *Renderer) {
+		r.transformers = append(r.transformers, transformers...)
+	}
+}
+
+// WithXHTML prints void elements and boolean attributes in XHTML style
+func WithXHTML() Option {
+	return func(r *Renderer) {
+		r.xhtml = true
+	}
+}
+
+// WithHeadingIDs adds id attribute made from the text to headings
+func WithHeadingIDs() Option {
+	return func(r *Renderer) {
+		r.headingID = true
+	}
+}
+
+// WithPermalinks adds heading ids and the links to them in headings
+func WithPermalinks() Option {
+	return func(r *Renderer) {
+		r.headingID = true
+		r.permalink = true
+	}
+}
+
+// WithUnsafe sets whether URLs and raw html are printed as they are.
+// By default URLs with the scheme out of the allowlist such as
+// "javascript:" are printed as empty, and raw html is filtered by the
+// policy. Use unsafe only for trusted markdown.
+func WithUnsafe(unsafe bool) Option {
+	return func(r *Renderer) {
+		r.unsafe = unsafe
+		r.rawHTML = unsafe
+	}
+}
+
+// WithAllowedSchemes enables the safe mode and replaces the allowlist
+// of URL schemes. Relative URLs are always allowed.
+func WithAllowedSchemes(schemes ...string) Option {
+	return func(r *Renderer) {
+		r.unsafe = false
+		r.rawHTML = false
+		r.schemes = toSchemeSet(schemes)
+	}
+}
+
+// WithHTMLPolicy enables the safe mode and replaces the allowlist
+// of raw html tags and attributes
+func WithHTMLPolicy(policy Policy) Option {
+	return func(r *Renderer) {
+		r.unsafe = false
+		r.rawHTML = false
+		r.policy = policy
+	}
+}
+
+// WithHook replaces the function which prints blocks of type t.
+// f can print the block in the default way with Context.Default.
+func WithHook(t ast.BlockType, f Func) Option {
+	return func(r *Renderer) {
+		r.hooks[t] = f
+	}
+}
+
+// WithParser sets the parser with extensions. Blocks of custom
+// types are printed by the hooks.
+func WithParser(p *ast.Parser) Option {
+	return func(r *Renderer) {
+		r.parser = p
+	}
+}
+
+// New returns the Renderer which prints blocks with funcs
+func New(funcs Funcs, opts ...Option) *Renderer {
+	r := &Renderer{
+		funcs:   funcs,
+		hooks:   make(Funcs),
+		parser:  ast.NewParser(),
+		schemes: toSchemeSet(DefaultSchemes),
+		policy:  DefaultPolicy,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Compile markdown to html
+func (r *Renderer) Compile(src string) (string, error) {
+	tree, err := r.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, 0, len(src)*2)
+	out = r.newContext(tree).Block(out, tree)
+	return string(out), nil
+}
+
+// Render writes the output to w block by block
+func (r *Renderer) Render(w io.Writer, src []byte) error {
+	tree, err := r.Parse(string(src))
+	if err != nil {
+		return err
+	}
+	c := r.newContext(tree)
+	out := make([]byte, 0, 1024)
+	for _, e := range tree.Children {
+		out = c.Block(out[:0], e)
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse parses src with the parser and the lenient mode, and
+// rewrites the tree with the transformers
+func (r *Renderer) Parse(src string) (*ast.Block, error) {
+	var tree *ast.Block
+	if r.lenient {
+		var warnings []*ast.ParseError
+		tree, warnings = r.parser.ParseLenient(src)
+		if r.warn != nil {
+			for _, w := range warnings {
+				r.warn(w)
+			}
+		}
+	} else {
+		var err error
+		if tree, err = r.parser.Parse(src); err != nil {
+			return nil, err
+		}
+	}
+	if err := ast.Transform(tree, r.transformers...); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (r *Renderer) newContext(tree *ast.Block) *Context {
+	c := &Context{
+		r:          r,
+		headingIDs: make(map[*ast.Block]string),
+		usedIDs:    make(map[string]bool),
+	}
+	// explicit ids are never used for other headings
+	ast.Walk(tree, func(node *ast.Block, entering bool) ast.WalkStatus {
+		if id := node.Attributes["id"]; entering && ast.IsHeading(node.Type) && len(id) > 0 {
+			c.headingIDs[node] = id
+			c.usedIDs[id] = true
+		}
+		return ast.WalkContinue
+	})
+	return c
+}
+
+// Context is passed to Func while printing a document
+type Context struct {
+	r *Renderer
+	// Header is true while the header row of the table is printed
+	Header bool
+
+	headingIDs map[*ast.Block]string
+	usedIDs    map[string]bool
+}
+
+// Block prints block with the hook or the default function
+func (c *Context) Block(out []byte, block *ast.Block) []byte {
+	if f, ok := c.r.hooks[block.Type]; ok {
+		return f(c, out, block)
+	}
+	return c.Default(out, block)
+}
+
+// Default prints block with the default function
+func (c *Context) Default(out []byte, block *ast.Block) []byte {
+	if f, ok := c.r.funcs[block.Type]; ok {
+		return f(c, out, block)
+	}
+	return out
+}
+
+// Children prints the children of block
+func (c *Context) Children(out []byte, block *ast.Block) []byte {
+	for _, e := range block.Children {
+		out = c.Block(out, e)
+	}
+	return out
+}
+
+// DefaultSchemes is the allowlist of URL schemes in the safe mode
+var DefaultSchemes = []string{"http", "https", "mailto"}
+
+func toSchemeSet(schemes []string) map[string]bool {
+	set := make(map[string]bool, len(schemes))
+	for _, s := range schemes {
+		set[strings.ToLower(s)] = true
+	}
+	return set
+}
+
+// URL returns empty in the safe mode if the scheme of url is not allowed
+func (c *Context) URL(url string) string {
+	if c.r.unsafe {
+		return url
+	}
+	return c.r.allowedURL(url)
+}
+
+// allowedURL returns empty if the scheme of url is not allowed
+func (r *Renderer) allowedURL(url string) string {
+	scheme, ok := urlScheme(url)
+	if !ok || r.schemes[scheme] {
+		return url
+	}
+	return ""
+}
+
+// urlScheme returns the lower case scheme of url. ok is false if url
+// is relative. Spaces and control chars are ignored like browsers.
+func urlScheme(url string) (scheme string, ok bool) {
+	chars := make([]byte, 0, len(url))
+	for i := 0; i < len(url); i++ {
+		char := url[i]
+		switch {
+		case char <= ' ' || char == 0x7f:
+			continue
+		case char == ':':
+			return strings.ToLower(string(chars)), len(chars) > 0
+		case char == '/' || char == '?' || char == '#':
+			return "", false
+		}
+		chars = append(chars, char)
+	}
+	return "", false
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+
+// Escape escapes text in the tree for html text and attribute value
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// AppendStr appends text to out
+func AppendStr(out []byte, text string) []byte {
+	return append(out, text...)
+}
@@ -0,0 +1,180 @@
+package render
+
+import (
+	"html"
+	"strings"
+
+	"github.com/mokelab-go/markdown/ast"
+)
+
+// Policy is the allowlist of raw html in the safe mode. Tags out of
+// the policy are removed and their text is printed.
+type Policy struct {
+	// Tags maps lower case tag names to their allowed attributes
+	Tags map[string][]string
+	// Attributes are allowed on all the tags in Tags
+	Attributes []string
+}
+
+// DefaultPolicy allows the tags for text formatting without scripts and styles
+var DefaultPolicy = Policy{
+	Tags: map[string][]string{
+		"a": {"href", "title"}, "abbr": {"title"}, "b": nil,
+		"blockquote": {"cite"}, "br": nil, "code": nil, "dd": nil,
+		"del": {"cite"}, "details": {"open"}, "div": nil, "dl": nil,
+		"dt": nil, "em": nil, "h1": nil, "h2": nil, "h3": nil,
+		"h4": nil, "h5": nil, "h6": nil, "hr": nil, "i": nil,
+		"img": {"src", "alt", "title", "width", "height"},
+		"ins": {"cite"}, "kbd": nil, "li": nil, "mark": nil,
+		"ol": {"start"}, "p": nil, "pre": nil, "q": {"cite"}, "s": nil,
+		"samp": nil, "small": nil, "span": nil, "strong": nil, "sub": nil,
+		"summary": nil, "sup": nil, "table": nil, "tbody": nil,
+		"td": {"align", "colspan", "rowspan"}, "tfoot": nil,
+		"th": {"align", "colspan", "rowspan"}, "thead": nil, "tr": nil,
+		"u": nil, "ul": nil, "var": nil,
+	},
+}
+
+func (p Policy) allows(tag, attr string) bool {
+	attrs, ok := p.Tags[tag]
+	if !ok || attr == "" {
+		return ok
+	}
+	return containsString(attrs, attr) || containsString(p.Attributes, attr)
+}
+
+// attributes whose value is URL
+var urlAttrs = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true,
+	"formaction": true, "poster": true, "background": true,
+}
+
+// tags whose content is removed with the tags
+var rawTextTags = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+	"iframe": true, "noscript": true, "noembed": true, "noframes": true,
+	"xmp": true,
+}
+
+// HTML prints raw html of the tree. Tags and attributes out of the
+// policy are removed, and comments and declarations too, unless raw
+// html is enabled by WithUnsafe(true). URLs in the attributes are
+// checked by the allowlist of schemes. forbidden reports whether the
+// tag (attr is empty) or the attribute of the tag is removed in any
+// mode. It may be nil.
+func (c *Context) HTML(out []byte, raw string, forbidden func(tag, attr string) bool) []byte {
+	if c.r.rawHTML && forbidden == nil {
+		return AppendStr(out, raw)
+	}
+	for len(raw) > 0 {
+		i := strings.IndexByte(raw, '<')
+		if i < 0 {
+			return AppendStr(out, raw)
+		}
+		out = AppendStr(out, raw[:i])
+		raw = raw[i:]
+		tag, n := ast.ReadHTMLTag(raw)
+		if n == 0 {
+			// not a tag
+			if c.r.rawHTML {
+				out = AppendStr(out, "<")
+			} else {
+				out = AppendStr(out, "&lt;")
+			}
+			raw = raw[1:]
+			continue
+		}
+		src := raw[:n]
+		raw = raw[n:]
+		switch {
+		case tag.Name == "":
+			// comments and declarations
+			if c.r.rawHTML {
+				out = AppendStr(out, src)
+			}
+		case !c.allowsTag(tag.Name, forbidden):
+			if !tag.Closing && rawTextTags[tag.Name] {
+				raw = skipRawText(raw, tag.Name)
+			}
+		case c.r.rawHTML && !hasForbidden(tag, forbidden):
+			out = AppendStr(out, src)
+		default:
+			out = c.appendTag(out, tag, forbidden)
+		}
+	}
+	return out
+}
+
+func (c *Context) allowsTag(tag string, forbidden func(tag, attr string) bool) bool {
+	if forbidden != nil && forbidden(tag, "") {
+		return false
+	}
+	return c.r.rawHTML || c.r.policy.allows(tag, "")
+}
+
+// appendTag prints the tag with the allowed attributes
+func (c *Context) appendTag(out []byte, tag ast.HTMLTag, forbidden func(tag, attr string) bool) []byte {
+	if tag.Closing {
+		return AppendStr(out, "</"+tag.Name+">")
+	}
+	out = AppendStr(out, "<"+tag.Name)
+	for _, attr := range tag.Attrs {
+		if forbidden != nil && forbidden(tag.Name, attr.Name) {
+			continue
+		}
+		if !c.r.rawHTML && !c.r.policy.allows(tag.Name, attr.Name) {
+			continue
+		}
+		if !attr.HasValue {
+			out = AppendStr(out, " "+attr.Name)
+			continue
+		}
+		value := html.UnescapeString(attr.Value)
+		if urlAttrs[attr.Name] && !c.r.rawHTML {
+			if value = c.r.allowedURL(value); value == "" {
+				continue
+			}
+		}
+		out = AppendStr(out, " "+attr.Name+"=\""+Escape(value)+"\"")
+	}
+	if tag.SelfClosing {
+		return AppendStr(out, " />")
+	}
+	return AppendStr(out, ">")
+}
+
+func hasForbidden(tag ast.HTMLTag, forbidden func(tag, attr string) bool) bool {
+	if forbidden == nil {
+		return false
+	}
+	for _, attr := range tag.Attrs {
+		if forbidden(tag.Name, attr.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRawText returns src after the closing tag of name
+func skipRawText(src, name string) string {
+	closing := "</" + name
+	for i := 0; i+len(closing) <= len(src); i++ {
+		if !strings.EqualFold(src[i:i+len(closing)], closing) {
+			continue
+		}
+		if end := strings.IndexByte(src[i:], '>'); end >= 0 {
+			return src[i+end+1:]
+		}
+		break
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}